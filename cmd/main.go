@@ -1,23 +1,35 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"piggy-bank/internal/app"
+	"piggy-bank/internal/engine"
 	"piggy-bank/internal/handlers"
+	"piggy-bank/internal/report"
+	"piggy-bank/internal/rng"
+	"piggy-bank/internal/rngcheck"
 	"piggy-bank/internal/simulator"
 )
 
+// calculationTolerance is the largest accepted difference between computed
+// and defined RTP values
+const calculationTolerance = 1e-6
+
 func main() {
 	application, err := app.NewApp("config.yaml")
 	if err != nil {
@@ -28,11 +40,119 @@ func main() {
 
 	addr := flag.String("addr", fmt.Sprintf(":%d", cfg.Server.Port), "HTTP server address")
 	sim := flag.Bool("simulate", false, "Run simulation mode")
+	calculate := flag.Bool("calculate", false, "Calculate exact RTP by enumerating reel stops and verify it against the game definition")
+	gamePath := flag.String("game", "", "Game definition file (.yaml or .json), built-in game if empty")
+	walletStorage := flag.String("wallet", "", "Wallet storage for player balances: memory or file, disabled if empty")
+	walletPath := flag.String("wallet-path", "wallet.jsonl", "Ledger file used by the file wallet storage")
+	adminToken := flag.String("admin-token", os.Getenv("ADMIN_TOKEN"), "Bearer token of the lobby and operators for wallet spins, balances, deposits and simulation jobs, $ADMIN_TOKEN by default, those requests are refused if empty")
+	historyPath := flag.String("history", "", "File to keep spin records for replay, memory if empty")
+	seed := flag.String("seed", "", "Seed for a deterministic RNG to reproduce simulations, calculations and RNG tests or back -rng-server, configured RNG if empty")
+	buckets := flag.String("buckets", "", "Comma separated award histogram bucket edges in multiples of the wager, defaults if empty")
+	percentiles := flag.String("percentiles", "", "Comma separated win size percentiles to report, defaults if empty")
+	checkpointInterval := flag.Int64("checkpoint-interval", 0, "Spins between RTP checkpoints of the simulation report, 1% of the spins if zero")
+	format := flag.String("format", "json", "Simulation report format: json, xlsx or csv")
+	snapshotPath := flag.String("snapshot", "", "File to periodically save the simulation state to for -resume, disabled if empty")
+	resumePath := flag.String("resume", "", "Resume the simulation saved in this snapshot file, which keeps being updated")
+	rngSecondary := flag.String("rng-secondary", "", "host:port of a secondary RNG server to fail over to when the configured one is unhealthy")
+	rngServer := flag.String("rng-server", "", "Serve the RNG gRPC service on this address, backed by crypto/rand or the -seed, instead of running the game")
+	rngTest := flag.Int("rng-test", 0, "Draw this many values per engine range from the RNG, run the statistical tests and exit, disabled if zero")
+	rngStrict := flag.Bool("rng-strict", false, "Fail instead of falling back to the non-certified mock RNG when no RNG server answers on start")
+	ciWidth := flag.Float64("ci-width", 0, "Stop the simulation once the 95% confidence interval of the RTP is narrower, e.g. 0.002 for +-0.1%, disabled if zero")
 
 	flag.Parse()
 
-	if *sim {
-		runSimulation(application, cfg.Simulator.Spins, cfg.Simulator.Wager, cfg.Simulator.Workers, cfg.Simulator.ReportPath)
+	if *gamePath != "" {
+		if err := application.LoadGame(*gamePath); err != nil {
+			log.Fatalf("Error loading game: %v", err)
+		}
+	}
+
+	if *rngSecondary != "" || *rngStrict {
+		if err := application.SetupRNG(rng.Options{SecondaryAddr: *rngSecondary, Strict: *rngStrict}); err != nil {
+			log.Fatalf("Error initializing RNG: %v", err)
+		}
+	}
+
+	// A seeded RNG is predictable, live spins always use the configured one
+	if (*seed != "" || *resumePath != "") && !*sim && !*calculate && *rngTest == 0 && *rngServer == "" {
+		log.Fatalf("-seed and -resume only apply with -simulate, -calculate, -rng-test or -rng-server, the game server never runs on a seeded RNG")
+	}
+
+	if *seed != "" {
+		value, err := strconv.ParseUint(*seed, 10, 64)
+		if err != nil {
+			log.Fatalf("Invalid seed %q: %v", *seed, err)
+		}
+		application.UseSeed(value)
+	}
+
+	if *walletStorage != "" {
+		if *adminToken == "" {
+			log.Fatalf("The wallet needs -admin-token, the lobby plays and reads balances on behalf of the players with it")
+		}
+
+		if err := application.SetupWallet(*walletStorage, *walletPath); err != nil {
+			log.Fatalf("Error initializing wallet: %v", err)
+		}
+	}
+
+	application.AdminToken = *adminToken
+
+	if *historyPath != "" {
+		if err := application.SetupHistory(*historyPath); err != nil {
+			log.Fatalf("Error initializing spin history: %v", err)
+		}
+	}
+
+	spins, wager := cfg.Simulator.Spins, cfg.Simulator.Wager
+
+	simOptions := simulator.Options{
+		CheckpointInterval: *checkpointInterval,
+		TargetCIWidth:      *ciWidth,
+		SnapshotPath:       *snapshotPath,
+	}
+	if simOptions.BucketEdges, err = parseFloats(*buckets); err != nil {
+		log.Fatalf("Invalid buckets %q: %v", *buckets, err)
+	}
+	if simOptions.Percentiles, err = parseFloats(*percentiles); err != nil {
+		log.Fatalf("Invalid percentiles %q: %v", *percentiles, err)
+	}
+
+	// A resumed simulation continues with the parameters and seed it was started with
+	if *resumePath != "" {
+		snapshot, err := simulator.LoadSnapshot(*resumePath)
+		if err != nil {
+			log.Fatalf("Error loading simulation snapshot: %v", err)
+		}
+
+		if _, seeded := application.GetRngService().Seed(); snapshot.Seed != nil && !seeded {
+			application.UseSeed(*snapshot.Seed)
+		}
+
+		spins, wager = snapshot.Count, snapshot.Wager
+
+		simOptions = snapshot.Options
+		simOptions.Resume = snapshot
+		simOptions.SnapshotPath = *resumePath
+		if *snapshotPath != "" {
+			simOptions.SnapshotPath = *snapshotPath
+		}
+
+		log.Printf("Resuming simulation of %d spins at %d spins from %s", spins, snapshot.Result.Count, *resumePath)
+	}
+
+	if *format != "json" && *format != "xlsx" && *format != "csv" {
+		log.Fatalf("Invalid report format %q, use json, xlsx or csv", *format)
+	}
+
+	if *rngTest > 0 {
+		runRNGTest(application, *rngTest)
+	} else if *rngServer != "" {
+		runRNGServer(*rngServer, *seed)
+	} else if *calculate {
+		runCalculation(application)
+	} else if *sim {
+		runSimulation(application, spins, wager, cfg.Simulator.Workers, cfg.Simulator.ReportPath, *format, simOptions)
 	} else {
 		startServer(application, *addr)
 	}
@@ -67,44 +187,235 @@ func startServer(app *app.App, address string) {
 	log.Print("Server shutdown completed")
 }
 
-func runSimulation(app *app.App, spins, wager int64, workers int, outputPath string) {
+// runRNGTest runs the statistical tests on the configured RNG and exits with
+// status 1 when any fails
+func runRNGTest(app *app.App, samples int) {
+	fmt.Printf("Testing RNG with %d samples per range %v and %d floats, alpha %v\n", samples, rngcheck.DefaultMaxes, samples, rngcheck.DefaultAlpha)
+
+	results, err := rngcheck.Run(app.GetRngService(), samples, rngcheck.DefaultMaxes, rngcheck.DefaultAlpha)
+	if err != nil {
+		log.Fatalf("RNG test failed: %v", err)
+	}
+
+	for _, result := range results {
+		fmt.Println(result)
+	}
+
+	if !rngcheck.Passed(results) {
+		fmt.Println("RNG test: FAIL")
+		os.Exit(1)
+	}
+
+	fmt.Println("RNG test: PASS")
+}
+
+// runRNGServer serves the RNG gRPC service until interrupted. It is not a
+// certified RNG, it runs the gRPC clients without an external service.
+func runRNGServer(address, seed string) {
+	srv := rng.NewServer()
+	if seed != "" {
+		value, err := strconv.ParseUint(seed, 10, 64)
+		if err != nil {
+			log.Fatalf("Invalid seed %q: %v", seed, err)
+		}
+		srv = rng.NewSeededServer(value)
+	}
+
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", address, err)
+	}
+
+	server := srv.GRPCServer()
+
+	done := make(chan os.Signal, 1)
+	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		log.Printf("Starting RNG server on %s", lis.Addr())
+		if err := server.Serve(lis); err != nil {
+			log.Fatalf("Failed to serve RNG: %v", err)
+		}
+	}()
+
+	<-done
+	log.Print("RNG server shutdown initiated...")
+
+	server.GracefulStop()
+
+	log.Print("RNG server shutdown completed")
+}
+
+func runSimulation(app *app.App, spins, wager int64, workers int, outputPath, format string, opts simulator.Options) {
 	fmt.Printf("Starting simulation with %d spins, wager %d, using %d workers\n", spins, wager, workers)
 
 	rngService := app.GetRngService()
 
-	result, err := simulator.Simulate("piggy-bank", spins, wager, workers, rngService)
-	if err != nil {
+	// Ctrl-C stops the workers and still writes the report of the completed spins
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := simulator.Simulate(ctx, app.GetGame(), spins, wager, workers, rngService, opts)
+	if result == nil {
 		log.Fatalf("Simulation failed: %v", err)
 	}
 
+	if err != nil {
+		log.Printf("Simulation interrupted after %d of %d spins: %v", result.Count, result.Requested, err)
+	}
+
 	if err := os.MkdirAll(outputPath, 0o755); err != nil {
 		log.Fatalf("Failed to create output directory: %v", err)
 	}
 
 	timestamp := time.Now().Format("2006-01-02-15-04-05")
-	filename := fmt.Sprintf("piggy-bank-sim-%s.json", timestamp)
-	fullPath := filepath.Join(outputPath, filename)
+	baseName := fmt.Sprintf("%s-sim-%s", app.GetGame().Name, timestamp)
 
-	jsonData, err := json.MarshalIndent(result.View(), "", "  ")
-	if err != nil {
-		log.Fatalf("Failed to marshal simulation results: %v", err)
-	}
+	view := result.View()
 
-	// Write to file
-	if err := os.WriteFile(fullPath, jsonData, 0o644); err != nil {
+	paths, err := writeSimulationReport(view, outputPath, baseName, format)
+	if err != nil {
 		log.Fatalf("Failed to write simulation results: %v", err)
 	}
 
 	// Also print summary to console
-	view := result.View()
 	fmt.Println("\n=== Simulation Results ===")
 	fmt.Printf("Game: %s\n", view.Game)
-	fmt.Printf("Spins: %s\n", view.Count)
+	fmt.Printf("Spins: %s of %s\n", view.Count, view.Requested)
 	fmt.Printf("Wager: %s\n", view.Wager)
 	fmt.Printf("Total Spent: %s\n", view.Spent)
 	fmt.Printf("Max Exposure: %s\n", view.MaxExposure)
 	fmt.Printf("RTP: %s%%\n", view.RTP)
+	fmt.Printf("RTP %s confidence: [%s%%, %s%%]\n", view.RTPConfidence95.Level, view.RTPConfidence95.Lower, view.RTPConfidence95.Upper)
+	fmt.Printf("RTP %s confidence: [%s%%, %s%%]\n", view.RTPConfidence99.Level, view.RTPConfidence99.Lower, view.RTPConfidence99.Upper)
+	if view.StoppedEarly {
+		fmt.Printf("Stopped early after %s spins, the confidence interval reached the target width\n", view.Count)
+	}
 	fmt.Printf("Hit Rate: %s\n", view.AwardRate)
 	fmt.Printf("Volatility: %.3f\n", view.Volatility)
-	fmt.Printf("\nDetailed report saved to: %s\n", fullPath)
+
+	if stats, ok := app.GetRngService().PoolStats(); ok {
+		fmt.Printf("RNG pool: %d hits, %d misses, %d refills (%d failed), %v average refill latency\n",
+			stats.Hits, stats.Misses, stats.Refills, stats.RefillErrors, stats.RefillLatency)
+	}
+
+	fmt.Println("\n=== Reelsets ===")
+	for _, reelset := range view.Reelsets {
+		fmt.Printf("%s: %s of spins, RTP %s%% (expected %s%%)\n", reelset.Name, reelset.Rate, reelset.RTP, reelset.ExpectedRTP)
+	}
+
+	fmt.Println("\n=== Award Distribution ===")
+	for _, bucket := range view.Histogram {
+		fmt.Printf("%-14s %s, RTP %s%%\n", bucket.Bucket, bucket.Rate, bucket.RTP)
+	}
+	for _, p := range view.Percentiles {
+		fmt.Printf("%s win: %s (%.3fx)\n", p.Percentile, p.Award, p.Multiplier)
+	}
+
+	fmt.Printf("\nDetailed report saved to: %s\n", strings.Join(paths, ", "))
+}
+
+// writeSimulationReport writes the report in the given format and returns the
+// written files. CSV reports are written as a file per sheet.
+func writeSimulationReport(view *simulator.SimulationView, outputPath, baseName, format string) ([]string, error) {
+	switch format {
+	case "json":
+		jsonData, err := json.MarshalIndent(view, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(outputPath, baseName+".json")
+
+		return []string{path}, os.WriteFile(path, jsonData, 0o644)
+
+	case "xlsx":
+		sheets, err := view.Sheets()
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		if err := report.WriteXLSX(&buf, sheets); err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(outputPath, baseName+".xlsx")
+
+		return []string{path}, os.WriteFile(path, buf.Bytes(), 0o644)
+
+	case "csv":
+		sheets, err := view.Sheets()
+		if err != nil {
+			return nil, err
+		}
+
+		var paths []string
+		for _, sheet := range sheets {
+			var buf bytes.Buffer
+			if err := report.WriteCSV(&buf, sheet); err != nil {
+				return nil, err
+			}
+
+			path := filepath.Join(outputPath, fmt.Sprintf("%s-%s.csv", baseName, strings.ToLower(sheet.Name)))
+			if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+				return nil, err
+			}
+			paths = append(paths, path)
+		}
+
+		return paths, nil
+	}
+
+	return nil, fmt.Errorf("unknown report format %q", format)
+}
+
+func runCalculation(app *app.App) {
+	game := app.GetGame()
+	fmt.Printf("Calculating exact RTP of %s over %d reelsets\n", game.Name, len(game.Reelsets))
+
+	startTime := time.Now()
+
+	report, err := engine.CalculateRTP(game)
+	if err != nil {
+		log.Fatalf("Calculation failed: %v", err)
+	}
+
+	fmt.Println("\n=== Calculation Results ===")
+	for _, rs := range report.Reelsets {
+		fmt.Printf("%s (weight %d): RTP %.10f (definition %.10f), Hit Rate %.6f, Variance %.6f\n",
+			rs.Name, rs.Weight, rs.RTP, rs.ExpectedRTP, rs.HitFrequency, rs.Variance)
+	}
+	fmt.Printf("Base RTP: %.10f (definition %.10f)\n", report.BaseRTP, report.ExpectedBaseRTP)
+	fmt.Printf("Hit Rate: %.6f\n", report.HitFrequency)
+	fmt.Printf("Variance: %.6f\n", report.Variance)
+	fmt.Printf("Free Spins Trigger Rate: %.6f\n", report.FreeSpinsTriggerProbability)
+	fmt.Printf("Free Spins per Spin: %.6f\n", report.FreeSpinsPerSpin)
+	fmt.Printf("Free Spins RTP: %.10f\n", report.FreeSpinsRTP)
+	fmt.Printf("Total RTP: %.10f\n", report.TotalRTP)
+	fmt.Printf("\nTime elapsed: %v\n", time.Since(startTime))
+
+	if err := report.Verify(calculationTolerance); err != nil {
+		log.Fatalf("Computed RTP disagrees with the game definition:\n%v", err)
+	}
+
+	fmt.Println("Computed RTP matches the game definition")
+}
+
+// parseFloats parses a comma separated list of numbers, nil if empty
+func parseFloats(list string) ([]float64, error) {
+	if list == "" {
+		return nil, nil
+	}
+
+	var values []float64
+	for _, field := range strings.Split(list, ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
 }
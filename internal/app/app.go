@@ -6,12 +6,22 @@ import (
 	"time"
 
 	"piggy-bank/config"
+	"piggy-bank/internal/engine"
+	"piggy-bank/internal/history"
 	"piggy-bank/internal/rng"
+	"piggy-bank/internal/wallet"
 )
 
 type App struct {
 	Config     *config.Config
 	RngService *rng.Service
+	Game       *engine.Game
+	Wallet     *wallet.Wallet
+	History    history.Store
+
+	// AdminToken authorizes deposits and simulation jobs, which are refused
+	// while it is empty
+	AdminToken string
 }
 
 func NewApp(configPath string) (*App, error) {
@@ -34,6 +44,8 @@ func NewApp(configPath string) (*App, error) {
 	app := &App{
 		Config:     cfg,
 		RngService: rngService,
+		Game:       engine.DefaultGame(),
+		History:    history.NewMemoryStore(history.DefaultCapacity),
 	}
 
 	log.Printf("App initialized successfully %v", time.Since(startTime))
@@ -47,3 +59,84 @@ func (a *App) GetConfig() *config.Config {
 func (a *App) GetRngService() *rng.Service {
 	return a.RngService
 }
+
+// SetupRNG replaces the RNG service with one created with opts, such as a
+// secondary server to fail over to
+func (a *App) SetupRNG(opts rng.Options) error {
+	rngService, err := rng.NewServiceWithOptions(a.Config, opts)
+	if err != nil {
+		return fmt.Errorf("error initializing RNG service: %w", err)
+	}
+
+	a.RngService.Close()
+	a.RngService = rngService
+
+	return nil
+}
+
+// UseSeed replaces the configured RNG with a deterministic seeded one
+func (a *App) UseSeed(seed uint64) {
+	a.RngService.Close()
+	a.RngService = rng.NewSeededService(seed)
+}
+
+func (a *App) GetGame() *engine.Game {
+	return a.Game
+}
+
+// LoadGame replaces the built-in game with the definition stored at path
+func (a *App) LoadGame(path string) error {
+	log.Printf("Loading game definition from %s", path)
+
+	game, err := engine.LoadGame(path)
+	if err != nil {
+		return err
+	}
+
+	a.Game = game
+	log.Printf("Game %s loaded with %d reelsets", game.Name, len(game.Reelsets))
+
+	return nil
+}
+
+func (a *App) GetWallet() *wallet.Wallet {
+	return a.Wallet
+}
+
+// SetupWallet enables player balances for spins. storage is "memory" or
+// "file", in which case the ledger is kept at path.
+func (a *App) SetupWallet(storage, path string) error {
+	switch storage {
+	case "memory":
+		a.Wallet = wallet.NewWallet(wallet.NewMemoryStorage())
+	case "file":
+		fileStorage, err := wallet.NewFileStorage(path)
+		if err != nil {
+			return err
+		}
+		a.Wallet = wallet.NewWallet(fileStorage)
+	default:
+		return fmt.Errorf("unknown wallet storage %q", storage)
+	}
+
+	log.Printf("Wallet enabled with %s storage", storage)
+
+	return nil
+}
+
+func (a *App) GetHistory() history.Store {
+	return a.History
+}
+
+// SetupHistory keeps spin records for replay in the file at path instead of memory
+func (a *App) SetupHistory(path string) error {
+	store, err := history.NewFileStore(path)
+	if err != nil {
+		return err
+	}
+
+	a.History = store
+	log.Printf("Spin history stored in %s", path)
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package engine
+
+// maxCascadeSteps caps the cascades of a spin, so strips that keep winning
+// can not cascade forever
+const maxCascadeSteps = 100
+
+// Cascade configures cascading reels: the cells of every win are removed, the
+// symbols above drop down and new symbols fall in from the reel strip above
+// the stops until the window no longer wins
+type Cascade struct {
+	// Multipliers of the awards of the cascade steps, the landed window
+	// being the first step. The last one holds for all further steps, every
+	// step pays 1x when empty.
+	Multipliers []int `json:"multipliers,omitempty" yaml:"multipliers,omitempty"`
+}
+
+// multiplier returns the multiplier of the award of the step
+func (c *Cascade) multiplier(step int) int {
+	if len(c.Multipliers) == 0 {
+		return 1
+	}
+
+	return c.Multipliers[min(step, len(c.Multipliers)-1)]
+}
+
+// CascadeStep is a window evaluated during cascades. Awards of the wins
+// include the multiplier of the step.
+type CascadeStep struct {
+	Window     *Window
+	LineWins   []LineWin
+	Multiplier int
+	Award      int64
+}
+
+// evaluate returns the award and wins of the landing. With cascades the
+// window is re-evaluated after every drop and each step is returned, the
+// wins of all steps are returned together.
+func (s *SpinFactory) evaluate(game *Game, landed *landing, wager int64) (int64, []LineWin, []*CascadeStep) {
+	if game.Cascade == nil {
+		award, lineWins := s.calculateAward(game, landed.window, wager)
+		return award, lineWins, nil
+	}
+
+	reels := game.Reelsets[landed.reelset].Reels
+	window := copyWindow(landed.window)
+
+	// Strip position of the top row of every reel
+	tops := make([]int, len(landed.stops))
+	copy(tops, landed.stops)
+
+	var (
+		totalAward int64
+		allWins    []LineWin
+		steps      []*CascadeStep
+	)
+
+	for step := 0; ; step++ {
+		award, lineWins := s.calculateAward(game, window, wager)
+
+		multiplier := game.Cascade.multiplier(step)
+		award *= int64(multiplier)
+		for i := range lineWins {
+			lineWins[i].Award *= int64(multiplier)
+		}
+
+		steps = append(steps, &CascadeStep{
+			Window:     window,
+			LineWins:   lineWins,
+			Multiplier: multiplier,
+			Award:      award,
+		})
+		totalAward += award
+		allWins = append(allWins, lineWins...)
+
+		if len(lineWins) == 0 || step+1 == maxCascadeSteps {
+			break
+		}
+
+		window = dropWinningCells(window, reels, tops, lineWins)
+	}
+
+	return totalAward, allWins, steps
+}
+
+// dropWinningCells returns the window after removing the cells of the wins.
+// The symbols left in a reel drop to the bottom and the free rows on top are
+// filled from the strip above the top row, moving tops up accordingly.
+func dropWinningCells(window *Window, reels [][]Symbol, tops []int, lineWins []LineWin) *Window {
+	won := make([][]bool, len(window.Symbols))
+	for col := range won {
+		won[col] = make([]bool, len(window.Symbols[col]))
+	}
+	for _, lineWin := range lineWins {
+		for _, pos := range lineWin.Positions {
+			won[pos.Col][pos.Row] = true
+		}
+	}
+
+	next := &Window{Symbols: make([][]Symbol, len(window.Symbols))}
+	if window.Multipliers != nil {
+		next.Multipliers = make([][]int, len(window.Symbols))
+	}
+
+	for col, reel := range window.Symbols {
+		height := len(reel)
+		symbols := make([]Symbol, height)
+		multipliers := make([]int, height)
+
+		// Keep the cells that did not win, bottom up
+		row := height - 1
+		for r := height - 1; r >= 0; r-- {
+			if !won[col][r] {
+				symbols[row] = reel[r]
+				multipliers[row] = window.multiplier(col, r)
+				row--
+			}
+		}
+
+		strip := reels[col]
+		for ; row >= 0; row-- {
+			tops[col] = (tops[col] - 1 + len(strip)) % len(strip)
+			symbols[row] = strip[tops[col]]
+		}
+
+		next.Symbols[col] = symbols
+		if next.Multipliers != nil {
+			next.Multipliers[col] = multipliers
+		}
+	}
+
+	return next
+}
+
+func copyWindow(window *Window) *Window {
+	symbols := make([][]Symbol, len(window.Symbols))
+	for i, col := range window.Symbols {
+		symbols[i] = append([]Symbol(nil), col...)
+	}
+
+	return &Window{
+		Symbols:     symbols,
+		Multipliers: copyMultipliers(window.Multipliers),
+	}
+}
+
+func copyCascadeSteps(steps []*CascadeStep) []*CascadeStep {
+	if steps == nil {
+		return nil
+	}
+
+	copied := make([]*CascadeStep, len(steps))
+	for i, step := range steps {
+		copied[i] = &CascadeStep{
+			Window:     copyWindow(step.Window),
+			LineWins:   copyLineWins(step.LineWins),
+			Multiplier: step.Multiplier,
+			Award:      step.Award,
+		}
+	}
+
+	return copied
+}
@@ -0,0 +1,265 @@
+package engine
+
+import (
+	"maps"
+	"slices"
+)
+
+// Evaluator finds the wins of a window and returns the total award with the
+// wins that make it up
+type Evaluator interface {
+	Evaluate(game *Game, window *Window, wager int64) (int64, []LineWin)
+}
+
+// Evaluation is how a game pays the symbols of a window
+type Evaluation string
+
+const (
+	PaylineEvaluation Evaluation = "paylines" // matches on the fixed paylines of the game, the default
+	WaysEvaluation    Evaluation = "ways"     // matches on adjacent reels anywhere in the window, e.g. 243 ways on 5x3
+	ClusterEvaluation Evaluation = "clusters" // groups of horizontally or vertically adjacent symbols
+)
+
+// NewEvaluator returns the evaluator of the evaluation mode
+func NewEvaluator(evaluation Evaluation) Evaluator {
+	switch evaluation {
+	case WaysEvaluation:
+		return WaysEvaluator{}
+	case ClusterEvaluation:
+		return ClusterEvaluator{}
+	default:
+		return PaylineEvaluator{}
+	}
+}
+
+// PaylineEvaluator pays the symbols on every payline of the game from the leftmost reel
+type PaylineEvaluator struct{}
+
+// Evaluate returns the award of the paying lines and their wins
+func (PaylineEvaluator) Evaluate(game *Game, window *Window, wager int64) (int64, []LineWin) {
+	totalAward := int64(0)
+	var lineWins []LineWin
+
+	// Check each payline
+	for lineIndex, payline := range game.Paylines {
+		// Get symbols and wild multipliers on this payline
+		symbols := make([]Symbol, len(payline))
+		multipliers := make([]int, len(payline))
+		for i, pos := range payline {
+			if pos.Col < len(window.Symbols) && pos.Row < len(window.Symbols[pos.Col]) {
+				symbols[i] = window.Symbols[pos.Col][pos.Row]
+				multipliers[i] = window.multiplier(pos.Col, pos.Row)
+			}
+		}
+
+		// Count consecutive symbols from left to right
+		symbol, count, multiplier, award := evaluateLine(game.Paytable, symbols, multipliers, game.MultiplierCombine, wager)
+		if award == 0 {
+			continue
+		}
+
+		positions := make([]Position, count)
+		copy(positions, payline[:count])
+
+		lineWins = append(lineWins, LineWin{
+			Payline:    lineIndex,
+			Symbol:     symbol,
+			Count:      count,
+			Positions:  positions,
+			Multiplier: multiplier,
+			Award:      award,
+		})
+		totalAward += award
+	}
+
+	return totalAward, lineWins
+}
+
+// WaysEvaluator pays every way a symbol forms on adjacent reels from the
+// leftmost one, a way being one matching cell per reel. The wins of a symbol
+// multiply by the number of matching cells on each reel. Wilds substitute
+// for every symbol, the Wild pay table entry only pays on paylines.
+type WaysEvaluator struct{}
+
+// Evaluate returns the award of the paying symbols and one win per symbol.
+// Multiplier of a win is the sum of the combined wild multipliers of its ways.
+func (WaysEvaluator) Evaluate(game *Game, window *Window, wager int64) (int64, []LineWin) {
+	totalAward := int64(0)
+	var lineWins []LineWin
+
+	for _, symbol := range slices.Sorted(maps.Keys(game.Paytable)) {
+		if symbol == Wild {
+			continue
+		}
+
+		// Ways by the combined multiplier of their wilds
+		ways := map[int]int64{game.MultiplierCombine.start(): 1}
+		var positions []Position
+
+		count := 0
+		for col, reel := range window.Symbols {
+			next := make(map[int]int64)
+			for row, cell := range reel {
+				if cell != symbol && cell != Wild {
+					continue
+				}
+
+				multiplier := 0
+				if cell == Wild {
+					multiplier = window.multiplier(col, row)
+				}
+
+				for combined, n := range ways {
+					next[game.MultiplierCombine.add(combined, multiplier)] += n
+				}
+				positions = append(positions, Position{Col: col, Row: row})
+			}
+
+			if len(next) == 0 {
+				break
+			}
+
+			ways = next
+			count++
+		}
+
+		pay, ok := game.Paytable[symbol][count]
+		if count < minLineCount || !ok {
+			continue
+		}
+
+		total, multiplier := int64(0), int64(0)
+		for combined, n := range ways {
+			total += n
+			multiplier += n * int64(game.MultiplierCombine.total(combined))
+		}
+
+		award := pay * multiplier * wager / 100
+		if award == 0 {
+			continue
+		}
+
+		lineWins = append(lineWins, LineWin{
+			Payline:    -1,
+			Symbol:     symbol,
+			Count:      count,
+			Positions:  positions,
+			Ways:       int(total),
+			Multiplier: int(multiplier),
+			Award:      award,
+		})
+		totalAward += award
+	}
+
+	return totalAward, lineWins
+}
+
+// ClusterEvaluator pays groups of horizontally or vertically adjacent cells
+// of a symbol by their size. A cluster pays the pay table entry of the
+// largest count not above its size. Wilds join the clusters of every symbol
+// next to them, wild-only groups do not pay.
+type ClusterEvaluator struct{}
+
+// Evaluate returns the award of the paying clusters and one win per cluster
+func (ClusterEvaluator) Evaluate(game *Game, window *Window, wager int64) (int64, []LineWin) {
+	totalAward := int64(0)
+	var lineWins []LineWin
+
+	for _, symbol := range slices.Sorted(maps.Keys(game.Paytable)) {
+		if symbol == Wild {
+			continue
+		}
+
+		clustered := make(map[Position]bool)
+
+		for col, reel := range window.Symbols {
+			for row, cell := range reel {
+				start := Position{Col: col, Row: row}
+				if cell != symbol || clustered[start] {
+					continue
+				}
+
+				cluster := findCluster(window, symbol, start)
+				for _, pos := range cluster {
+					if window.Symbols[pos.Col][pos.Row] == symbol {
+						clustered[pos] = true
+					}
+				}
+
+				pay, ok := clusterPay(game.Paytable[symbol], len(cluster))
+				if !ok {
+					continue
+				}
+
+				multiplier := game.MultiplierCombine.start()
+				for _, pos := range cluster {
+					if window.Symbols[pos.Col][pos.Row] == Wild {
+						multiplier = game.MultiplierCombine.add(multiplier, window.multiplier(pos.Col, pos.Row))
+					}
+				}
+				multiplier = game.MultiplierCombine.total(multiplier)
+
+				award := pay * int64(multiplier) * wager / 100
+				if award == 0 {
+					continue
+				}
+
+				lineWins = append(lineWins, LineWin{
+					Payline:    -1,
+					Symbol:     symbol,
+					Count:      len(cluster),
+					Positions:  cluster,
+					Multiplier: multiplier,
+					Award:      award,
+				})
+				totalAward += award
+			}
+		}
+	}
+
+	return totalAward, lineWins
+}
+
+// findCluster returns the cells of symbol or Wild connected to start, in the
+// order they are reached
+func findCluster(window *Window, symbol Symbol, start Position) []Position {
+	seen := map[Position]bool{start: true}
+	cluster := []Position{start}
+
+	for i := 0; i < len(cluster); i++ {
+		pos := cluster[i]
+		for _, next := range []Position{
+			{Col: pos.Col - 1, Row: pos.Row},
+			{Col: pos.Col + 1, Row: pos.Row},
+			{Col: pos.Col, Row: pos.Row - 1},
+			{Col: pos.Col, Row: pos.Row + 1},
+		} {
+			if next.Col < 0 || next.Col >= len(window.Symbols) || next.Row < 0 || next.Row >= len(window.Symbols[next.Col]) || seen[next] {
+				continue
+			}
+
+			if cell := window.Symbols[next.Col][next.Row]; cell == symbol || cell == Wild {
+				seen[next] = true
+				cluster = append(cluster, next)
+			}
+		}
+	}
+
+	return cluster
+}
+
+// clusterPay returns the pay of the largest count of pays not above size
+func clusterPay(pays map[int]int64, size int) (int64, bool) {
+	best := 0
+	for count := range pays {
+		if count <= size && count > best {
+			best = count
+		}
+	}
+
+	if best == 0 {
+		return 0, false
+	}
+
+	return pays[best], true
+}
@@ -0,0 +1,388 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Game describes the math of a slot game: reelsets with their weights,
+// paylines, pay table and the free spins feature
+type Game struct {
+	Name           string
+	Reelsets       []*Reels
+	ReelsetData    []ReelsetData
+	Paylines       [][]Position
+	Paytable       map[Symbol]map[int]int64
+	BonusFreeSpins map[int]int
+	MaxFreeSpins   int
+	TotalRTP       float64
+	// MultiplierCombine is how wild multipliers on a line combine, multiply when empty
+	MultiplierCombine MultiplierCombine
+	// Evaluation selects the evaluator of the spin factory, paylines when empty
+	Evaluation Evaluation
+	// Cascade turns on cascading reels, nil without
+	Cascade *Cascade
+	// Shape is the number of visible rows of every reel, WindowHeight when empty
+	Shape WindowShape
+}
+
+// DefaultGame returns the built-in Piggy Bank game
+func DefaultGame() *Game {
+	return &Game{
+		Name:           "piggy-bank",
+		Reelsets:       []*Reels{reel1, reel2, reel3, reel4},
+		ReelsetData:    AllReelsetData,
+		Paylines:       Paylines,
+		Paytable:       symbolMultipliers,
+		BonusFreeSpins: BonusFreeSpins,
+		MaxFreeSpins:   MaxFreeSpins,
+		TotalRTP:       TotalRTP,
+		Evaluation:     PaylineEvaluation,
+	}
+}
+
+// GameDefinition is the file representation of a Game.
+// Symbols are referenced by name and paylines list the row for every reel.
+type GameDefinition struct {
+	Name         string                   `json:"name" yaml:"name"`
+	Paytable     map[string]map[int]int64 `json:"paytable" yaml:"paytable"`
+	Paylines     [][]int                  `json:"paylines" yaml:"paylines"`
+	Reelsets     []ReelsetDefinition      `json:"reelsets" yaml:"reelsets"`
+	FreeSpins    map[int]int              `json:"free_spins" yaml:"free_spins"`
+	MaxFreeSpins int                      `json:"max_free_spins" yaml:"max_free_spins"`
+	TotalRTP     float64                  `json:"total_rtp" yaml:"total_rtp"`
+
+	MultiplierCombine MultiplierCombine `json:"multiplier_combine,omitempty" yaml:"multiplier_combine,omitempty"`
+	Evaluation        Evaluation        `json:"evaluation,omitempty" yaml:"evaluation,omitempty"`
+	Cascade           *Cascade          `json:"cascade,omitempty" yaml:"cascade,omitempty"`
+	Window            WindowShape       `json:"window,omitempty" yaml:"window,omitempty"`
+}
+
+// ReelsetDefinition is the file representation of a reelset and its ReelsetData
+type ReelsetDefinition struct {
+	Name        string     `json:"name" yaml:"name"`
+	Weight      int        `json:"weight" yaml:"weight"`
+	Probability float64    `json:"probability,omitempty" yaml:"probability,omitempty"`
+	Wilds       Wilds      `json:"wilds,omitempty" yaml:"wilds,omitempty"`
+	RTP         float64    `json:"rtp" yaml:"rtp"`
+	Reels       [][]string `json:"reels" yaml:"reels"`
+
+	// WildsProbability is no longer supported, a definition setting it is
+	// rejected instead of silently losing its wilds
+	WildsProbability float64 `json:"wilds_probability,omitempty" yaml:"wilds_probability,omitempty"`
+}
+
+// LoadGame reads a game definition from a .yaml, .yml or .json file and builds the game
+func LoadGame(path string) (*Game, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read game definition: %w", err)
+	}
+
+	def := &GameDefinition{}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, def)
+	case ".json":
+		err = json.Unmarshal(data, def)
+	default:
+		return nil, fmt.Errorf("unsupported game definition format %q", ext)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse game definition %s: %w", path, err)
+	}
+
+	game, err := def.Build()
+	if err != nil {
+		return nil, fmt.Errorf("invalid game definition %s: %w", path, err)
+	}
+
+	return game, nil
+}
+
+// Build validates the definition and converts it into a Game.
+// All problems found are reported together.
+func (d *GameDefinition) Build() (*Game, error) {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	game := &Game{
+		Name:           d.Name,
+		Paytable:       make(map[Symbol]map[int]int64, len(d.Paytable)),
+		BonusFreeSpins: make(map[int]int, len(d.FreeSpins)),
+		MaxFreeSpins:   d.MaxFreeSpins,
+		TotalRTP:       d.TotalRTP,
+
+		MultiplierCombine: d.MultiplierCombine,
+		Evaluation:        d.Evaluation,
+		Cascade:           d.Cascade,
+		Shape:             d.Window,
+	}
+
+	if d.Name == "" {
+		fail("name: must not be empty")
+	}
+
+	switch d.MultiplierCombine {
+	case "", MultiplyMultipliers, AddMultipliers:
+	default:
+		fail("multiplier_combine: must be %q or %q, got %q", MultiplyMultipliers, AddMultipliers, d.MultiplierCombine)
+	}
+
+	switch d.Evaluation {
+	case "":
+		game.Evaluation = PaylineEvaluation
+	case PaylineEvaluation, WaysEvaluation, ClusterEvaluation:
+	default:
+		fail("evaluation: must be %q, %q or %q, got %q", PaylineEvaluation, WaysEvaluation, ClusterEvaluation, d.Evaluation)
+	}
+
+	if d.Cascade != nil {
+		for i, multiplier := range d.Cascade.Multipliers {
+			if multiplier < 1 {
+				fail("cascade.multipliers[%d]: must be at least 1, got %d", i, multiplier)
+			}
+		}
+	}
+
+	if len(d.Reelsets) == 0 {
+		fail("reelsets: at least one reelset is required")
+	}
+
+	width := 0
+	if len(d.Reelsets) > 0 {
+		width = len(d.Reelsets[0].Reels)
+	}
+
+	d.validateWindow(width, fail)
+
+	totalWeight := 0
+	for i, rs := range d.Reelsets {
+		if rs.Weight < 0 {
+			fail("reelsets[%d].weight: must not be negative, got %d", i, rs.Weight)
+		}
+		totalWeight += rs.Weight
+	}
+
+	if len(d.Reelsets) > 0 && totalWeight <= 0 {
+		fail("reelsets: weights must sum to a positive value, got %d", totalWeight)
+	}
+
+	for i, rs := range d.Reelsets {
+		if len(rs.Reels) == 0 {
+			fail("reelsets[%d].reels: at least one reel is required", i)
+		} else if len(rs.Reels) != width {
+			fail("reelsets[%d].reels: has %d reels, reelsets[0] has %d", i, len(rs.Reels), width)
+		}
+
+		if rs.WildsProbability != 0 {
+			fail("reelsets[%d].wilds_probability: is replaced by wilds, e.g. wild_reels: [0, 0, 0, 0, 0, 1] turns every reel wild", i)
+		}
+
+		if len(rs.Wilds.WildReels) > 0 {
+			wildReelsWeight := 0
+			for count, weight := range rs.Wilds.WildReels {
+				if weight < 0 {
+					fail("reelsets[%d].wilds.wild_reels[%d]: weight must not be negative, got %d", i, count, weight)
+				}
+				wildReelsWeight += weight
+			}
+
+			if len(rs.Wilds.WildReels)-1 > len(rs.Reels) {
+				fail("reelsets[%d].wilds.wild_reels: has weights for up to %d wild reels, reelset has %d reels", i, len(rs.Wilds.WildReels)-1, len(rs.Reels))
+			}
+
+			if wildReelsWeight <= 0 {
+				fail("reelsets[%d].wilds.wild_reels: weights must sum to a positive value, got %d", i, wildReelsWeight)
+			}
+		}
+
+		if len(rs.Wilds.Multipliers) > 0 {
+			multipliersWeight := 0
+			for j, multiplier := range rs.Wilds.Multipliers {
+				if multiplier.Value < 1 {
+					fail("reelsets[%d].wilds.multipliers[%d].value: must be at least 1, got %d", i, j, multiplier.Value)
+				}
+				if multiplier.Weight < 0 {
+					fail("reelsets[%d].wilds.multipliers[%d].weight: must not be negative, got %d", i, j, multiplier.Weight)
+				}
+				multipliersWeight += multiplier.Weight
+			}
+
+			if multipliersWeight <= 0 {
+				fail("reelsets[%d].wilds.multipliers: weights must sum to a positive value, got %d", i, multipliersWeight)
+			}
+		}
+
+		probability := 0.0
+		if totalWeight > 0 {
+			probability = float64(rs.Weight) / float64(totalWeight)
+		}
+
+		if rs.Probability != 0 && math.Abs(rs.Probability-probability) > 1e-9 {
+			fail("reelsets[%d].probability: %v does not match weight %d of total %d (%v)",
+				i, rs.Probability, rs.Weight, totalWeight, probability)
+		}
+
+		reels := &Reels{Reels: make([][]Symbol, len(rs.Reels))}
+		for j, reel := range rs.Reels {
+			if height := game.Shape.maxHeight(j); len(reel) < height {
+				fail("reelsets[%d].reels[%d]: has %d symbols, window height is %d", i, j, len(reel), height)
+			}
+
+			reels.Reels[j] = make([]Symbol, len(reel))
+			for k, name := range reel {
+				symbol, err := ParseSymbol(name)
+				if err != nil {
+					fail("reelsets[%d].reels[%d][%d]: %w", i, j, k, err)
+				}
+				reels.Reels[j][k] = symbol
+			}
+		}
+
+		game.Reelsets = append(game.Reelsets, reels)
+		game.ReelsetData = append(game.ReelsetData, ReelsetData{
+			Name:        rs.Name,
+			Weight:      rs.Weight,
+			Probability: probability,
+			Wilds:       rs.Wilds,
+			RTP:         rs.RTP,
+		})
+	}
+
+	switch {
+	case game.Evaluation != PaylineEvaluation && len(d.Paylines) > 0:
+		fail("paylines: %s evaluation pays without paylines, got %d", game.Evaluation, len(d.Paylines))
+	case game.Evaluation == PaylineEvaluation && len(d.Paylines) == 0:
+		fail("paylines: at least one payline is required")
+	}
+
+	for i, rows := range d.Paylines {
+		if len(rows) != width {
+			fail("paylines[%d]: has %d positions, window has %d reels", i, len(rows), width)
+		}
+
+		payline := make([]Position, len(rows))
+		for col, row := range rows {
+			if height := game.Shape.minHeight(col); row < 0 || row >= height {
+				fail("paylines[%d][%d]: row %d is outside the window of height %d", i, col, row, height)
+			}
+			payline[col] = Position{Col: col, Row: row}
+		}
+
+		game.Paylines = append(game.Paylines, payline)
+	}
+
+	if len(d.Paytable) == 0 {
+		fail("paytable: at least one symbol is required")
+	}
+
+	// Clusters pay by their size, which can cover the whole window
+	maxCount := width
+	if game.Evaluation == ClusterEvaluation {
+		maxCount = 0
+		for col := 0; col < width; col++ {
+			maxCount += game.Shape.maxHeight(col)
+		}
+	}
+
+	for name, pays := range d.Paytable {
+		symbol, err := ParseSymbol(name)
+		if err != nil {
+			fail("paytable.%s: %w", name, err)
+			continue
+		}
+
+		game.Paytable[symbol] = make(map[int]int64, len(pays))
+		for count, pay := range pays {
+			if count < 1 || count > maxCount {
+				fail("paytable.%s: count %d is outside [1, %d]", name, count, maxCount)
+			}
+			if pay < 0 {
+				fail("paytable.%s.%d: pay must not be negative, got %d", name, count, pay)
+			}
+			game.Paytable[symbol][count] = pay
+		}
+	}
+
+	for count, spins := range d.FreeSpins {
+		if count < 1 {
+			fail("free_spins.%d: bonus count must be positive", count)
+		}
+		if spins < 1 {
+			fail("free_spins.%d: must award at least one free spin, got %d", count, spins)
+		}
+		game.BonusFreeSpins[count] = spins
+	}
+
+	if len(d.FreeSpins) > 0 && d.MaxFreeSpins < 1 {
+		fail("max_free_spins: must be positive when free_spins are defined, got %d", d.MaxFreeSpins)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return game, nil
+}
+
+// validateWindow checks the window shape against the number of reels
+func (d *GameDefinition) validateWindow(width int, fail func(format string, args ...interface{})) {
+	if len(d.Window.Heights) > 0 {
+		if len(d.Window.HeightWeights) > 0 {
+			fail("window: heights and height_weights can not be used together")
+		}
+
+		if len(d.Window.Heights) != width {
+			fail("window.heights: has %d reels, reelsets have %d", len(d.Window.Heights), width)
+		}
+
+		for col, height := range d.Window.Heights {
+			if height < 1 {
+				fail("window.heights[%d]: must be at least 1, got %d", col, height)
+			}
+		}
+	}
+
+	if len(d.Window.HeightWeights) > 0 {
+		heightsWeight := 0
+		for height, weight := range d.Window.HeightWeights {
+			if weight < 0 {
+				fail("window.height_weights[%d]: weight must not be negative, got %d", height, weight)
+			}
+			heightsWeight += weight
+		}
+
+		if d.Window.HeightWeights[0] != 0 {
+			fail("window.height_weights[0]: a reel shows at least one row, got weight %d", d.Window.HeightWeights[0])
+		}
+
+		if heightsWeight <= 0 {
+			fail("window.height_weights: weights must sum to a positive value, got %d", heightsWeight)
+		}
+	}
+}
+
+// freeSpinsForBonusCount returns the number of free spins awarded for the
+// given number of Bonus symbols, using the highest matching entry of BonusFreeSpins
+func (g *Game) freeSpinsForBonusCount(count int) int {
+	best, spins := 0, 0
+	for required, awarded := range g.BonusFreeSpins {
+		if count >= required && required > best {
+			best, spins = required, awarded
+		}
+	}
+
+	return spins
+}
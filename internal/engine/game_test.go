@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestLoadGameMatchesDefault проверяет, что файл описания игры совпадает со встроенной игрой
+func TestLoadGameMatchesDefault(t *testing.T) {
+	game, err := LoadGame("../../games/piggy-bank.yaml")
+	if err != nil {
+		t.Fatalf("LoadGame() error = %v", err)
+	}
+
+	want := DefaultGame()
+
+	if game.Name != want.Name {
+		t.Errorf("Game.Name = %v, want %v", game.Name, want.Name)
+	}
+
+	if !reflect.DeepEqual(game.Reelsets, want.Reelsets) {
+		t.Error("Game.Reelsets differ from the built-in reelsets")
+	}
+
+	if !reflect.DeepEqual(game.Paylines, want.Paylines) {
+		t.Error("Game.Paylines differ from the built-in paylines")
+	}
+
+	if !reflect.DeepEqual(game.Paytable, want.Paytable) {
+		t.Errorf("Game.Paytable = %v, want %v", game.Paytable, want.Paytable)
+	}
+
+	if !reflect.DeepEqual(game.BonusFreeSpins, want.BonusFreeSpins) || game.MaxFreeSpins != want.MaxFreeSpins {
+		t.Errorf("free spins = %v/%d, want %v/%d", game.BonusFreeSpins, game.MaxFreeSpins, want.BonusFreeSpins, want.MaxFreeSpins)
+	}
+
+	if !reflect.DeepEqual(game.ReelsetData, want.ReelsetData) {
+		t.Errorf("Game.ReelsetData = %+v, want %+v", game.ReelsetData, want.ReelsetData)
+	}
+}
+
+// TestGameDefinitionBuildErrors проверяет сообщения об ошибках валидации
+func TestGameDefinitionBuildErrors(t *testing.T) {
+	def := &GameDefinition{
+		Name:              "broken",
+		MultiplierCombine: "sum",
+		Cascade:           &Cascade{Multipliers: []int{1, 0}},
+		Paytable:          map[string]map[int]int64{"DYNAMIT": {3: 30}, "BAT": {6: 10}},
+		Paylines:          [][]int{{1, 1, 1}, {0, 3, 0}},
+		Reelsets: []ReelsetDefinition{
+			{Name: "first", Weight: 1, Probability: 0.5, Wilds: Wilds{WildReels: []int{1, 1, 1, 1, 1}}, Reels: [][]string{{"A", "K", "Q"}, {"A", "K", "Q"}, {"A", "K"}}},
+			{Name: "second", Weight: 3, WildsProbability: 1, Wilds: Wilds{Multipliers: []WildMultiplier{{Value: 0, Weight: 0}}}, Reels: [][]string{{"A", "K", "Q"}, {"A", "K", "PIG"}}},
+		},
+	}
+
+	_, err := def.Build()
+	if err == nil {
+		t.Fatal("GameDefinition.Build() error = nil, want validation errors")
+	}
+
+	for _, want := range []string{
+		`reelsets[0].probability: 0.5 does not match weight 1 of total 4`,
+		`reelsets[0].reels[2]: has 2 symbols, window height is 3`,
+		`reelsets[0].wilds.wild_reels: has weights for up to 4 wild reels, reelset has 3 reels`,
+		`reelsets[1].reels: has 2 reels, reelsets[0] has 3`,
+		`reelsets[1].wilds_probability: is replaced by wilds`,
+		`reelsets[1].wilds.multipliers[0].value: must be at least 1, got 0`,
+		`reelsets[1].wilds.multipliers: weights must sum to a positive value, got 0`,
+		`reelsets[1].reels[1][2]: unknown symbol "PIG"`,
+		`paylines[1][1]: row 3 is outside the window of height 3`,
+		`paytable.DYNAMIT: unknown symbol "DYNAMIT"`,
+		`paytable.BAT: count 6 is outside [1, 3]`,
+		`multiplier_combine: must be "multiply" or "add", got "sum"`,
+		`cascade.multipliers[1]: must be at least 1, got 0`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("GameDefinition.Build() error does not contain %q:\n%v", want, err)
+		}
+	}
+}
+
+// TestGameDefinitionBuildEvaluation проверяет выбор режима выплат и его связь с линиями
+func TestGameDefinitionBuildEvaluation(t *testing.T) {
+	reelsets := []ReelsetDefinition{{Name: "main", Weight: 1, Reels: [][]string{{"A", "K", "Q"}, {"A", "K", "Q"}, {"A", "K", "Q"}}}}
+	paytable := map[string]map[int]int64{"A": {3: 5}}
+
+	tests := []struct {
+		name       string
+		evaluation Evaluation
+		paylines   [][]int
+		want       Evaluation
+		wantErr    string
+	}{
+		{name: "Paylines by default", paylines: [][]int{{1, 1, 1}}, want: PaylineEvaluation},
+		{name: "Ways", evaluation: WaysEvaluation, want: WaysEvaluation},
+		{name: "Ways with paylines", evaluation: WaysEvaluation, paylines: [][]int{{1, 1, 1}}, wantErr: "paylines: ways evaluation pays without paylines"},
+		{name: "Paylines without paylines", evaluation: PaylineEvaluation, wantErr: "paylines: at least one payline is required"},
+		{name: "Clusters", evaluation: ClusterEvaluation, want: ClusterEvaluation},
+		{name: "Unknown", evaluation: "scatter", paylines: [][]int{{1, 1, 1}}, wantErr: `evaluation: must be "paylines", "ways" or "clusters", got "scatter"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := &GameDefinition{
+				Name:       "evaluation",
+				Paytable:   paytable,
+				Paylines:   tt.paylines,
+				Reelsets:   reelsets,
+				Evaluation: tt.evaluation,
+			}
+
+			game, err := def.Build()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GameDefinition.Build() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("GameDefinition.Build() error = %v", err)
+			}
+
+			if game.Evaluation != tt.want {
+				t.Errorf("Game.Evaluation = %q, want %q", game.Evaluation, tt.want)
+			}
+		})
+	}
+}
+
+// TestGameDefinitionBuildWindow проверяет форму окна и линии относительно нее
+func TestGameDefinitionBuildWindow(t *testing.T) {
+	reel := []string{"A", "K", "Q", "J", "A"}
+	reels := [][]string{reel, reel, reel}
+
+	tests := []struct {
+		name     string
+		window   WindowShape
+		paylines [][]int
+		wantErr  []string
+	}{
+		{
+			name:     "Fixed heights",
+			window:   WindowShape{Heights: []int{3, 5, 3}},
+			paylines: [][]int{{0, 4, 0}},
+		},
+		{
+			name:     "Payline below a short reel",
+			window:   WindowShape{Heights: []int{2, 5, 3}},
+			paylines: [][]int{{2, 4, 2}},
+			wantErr:  []string{"paylines[0][0]: row 2 is outside the window of height 2"},
+		},
+		{
+			name:     "Random heights keep paylines in the fewest rows",
+			window:   WindowShape{HeightWeights: []int{0, 0, 1, 1}},
+			paylines: [][]int{{1, 1, 1}, {2, 2, 2}},
+			wantErr:  []string{"paylines[1][0]: row 2 is outside the window of height 2"},
+		},
+		{
+			name:     "Invalid shape",
+			window:   WindowShape{Heights: []int{3, 0, 6, 3}, HeightWeights: []int{1, -1}},
+			paylines: [][]int{{0, 0, 0}},
+			wantErr: []string{
+				"window: heights and height_weights can not be used together",
+				"window.heights: has 4 reels, reelsets have 3",
+				"window.heights[1]: must be at least 1, got 0",
+				"window.height_weights[1]: weight must not be negative, got -1",
+				"window.height_weights[0]: a reel shows at least one row, got weight 1",
+				"window.height_weights: weights must sum to a positive value, got 0",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := &GameDefinition{
+				Name:     "window",
+				Paytable: map[string]map[int]int64{"A": {3: 5}},
+				Paylines: tt.paylines,
+				Reelsets: []ReelsetDefinition{{Name: "main", Weight: 1, Reels: reels}},
+				Window:   tt.window,
+			}
+
+			_, err := def.Build()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("GameDefinition.Build() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("GameDefinition.Build() error = nil, want %q", tt.wantErr)
+			}
+
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("GameDefinition.Build() error does not contain %q:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// DrawLayoutVersion identifies the order and meaning of the draws a spin
+// consumes. It has to be bumped with every change of the layout, recorded
+// draws only replay on the layout they were recorded with.
+const DrawLayoutVersion = 1
+
+var (
+	ErrReplayExhausted = errors.New("replay ran out of recorded draws")
+	ErrReplayMismatch  = errors.New("replay requested a different range than recorded")
+)
+
+// Draw is a single random number consumed by a spin
+type Draw struct {
+	Max   uint64 `json:"max"`
+	Value uint64 `json:"value"`
+}
+
+// RecordingRNG passes draws through to the wrapped RNG and records them
+type RecordingRNG struct {
+	rng   RNG
+	Draws []Draw
+}
+
+func NewRecordingRNG(rng RNG) *RecordingRNG {
+	return &RecordingRNG{rng: rng}
+}
+
+func (r *RecordingRNG) Rand(max uint64) (uint64, error) {
+	val, err := r.rng.Rand(max)
+	if err != nil {
+		return 0, err
+	}
+
+	r.Draws = append(r.Draws, Draw{Max: max, Value: val})
+
+	return val, nil
+}
+
+func (r *RecordingRNG) RandSlice(maxSlice []uint64) ([]uint64, error) {
+	vals, err := r.rng.RandSlice(maxSlice)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vals) != len(maxSlice) {
+		return nil, fmt.Errorf("RNG returned %d random numbers, requested %d", len(vals), len(maxSlice))
+	}
+
+	for i, max := range maxSlice {
+		r.Draws = append(r.Draws, Draw{Max: max, Value: vals[i]})
+	}
+
+	return vals, nil
+}
+
+// ReplayRNG returns recorded draws in order. The first failure is kept
+// so that draws whose errors the engine ignores are still reported.
+type ReplayRNG struct {
+	draws []Draw
+	index int
+	err   error
+}
+
+func NewReplayRNG(draws []Draw) *ReplayRNG {
+	return &ReplayRNG{draws: draws}
+}
+
+func (r *ReplayRNG) Rand(max uint64) (uint64, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	if r.index >= len(r.draws) {
+		r.err = ErrReplayExhausted
+		return 0, r.err
+	}
+
+	draw := r.draws[r.index]
+	if draw.Max != max {
+		r.err = fmt.Errorf("%w: draw %d recorded max %d, requested %d", ErrReplayMismatch, r.index, draw.Max, max)
+		return 0, r.err
+	}
+
+	r.index++
+
+	return draw.Value, nil
+}
+
+// RandSlice returns the next recorded draws, one per element of maxSlice
+func (r *ReplayRNG) RandSlice(maxSlice []uint64) ([]uint64, error) {
+	vals := make([]uint64, len(maxSlice))
+	for i, max := range maxSlice {
+		val, err := r.Rand(max)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = val
+	}
+
+	return vals, nil
+}
+
+// Err returns the first replay failure, or an error if recorded draws were left unused
+func (r *ReplayRNG) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+
+	if r.index != len(r.draws) {
+		return fmt.Errorf("replay used %d of %d recorded draws", r.index, len(r.draws))
+	}
+
+	return nil
+}
+
+// WithRNG returns a copy of the factory drawing from rng
+func (s *SpinFactory) WithRNG(rng RNG) *SpinFactory {
+	factory := *s
+	factory.rng = rng
+
+	return &factory
+}
+
+// Hash identifies the math of the game, spins recorded on a game only
+// replay on a game with the same hash
+func (g *Game) Hash() string {
+	// The game only holds plain data, which always marshals
+	data, err := json.Marshal(g)
+	if err != nil {
+		panic(fmt.Sprintf("can not hash game %s: %v", g.Name, err))
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateRecorded creates a new spin and records the draws it consumed in Spin.Draws
+func (s *SpinFactory) GenerateRecorded(wager int64) (*Spin, error) {
+	recorder := NewRecordingRNG(s.rng)
+
+	spin, err := s.WithRNG(recorder).Generate(wager)
+	if err != nil {
+		return nil, err
+	}
+
+	spin.Draws = recorder.Draws
+
+	return spin, nil
+}
+
+// Replay re-derives a spin from its recorded draws. It fails if the spin
+// does not consume exactly the recorded draws.
+func (s *SpinFactory) Replay(wager int64, draws []Draw) (*Spin, error) {
+	replay := NewReplayRNG(draws)
+
+	spin, err := s.WithRNG(replay).Generate(wager)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := replay.Err(); err != nil {
+		return nil, err
+	}
+
+	spin.Draws = draws
+
+	return spin, nil
+}
@@ -0,0 +1,742 @@
+package engine
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/bits"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// minLineCount is the shortest line that can pay, lines that can not reach it
+// after this many reels are not enumerated any further
+const minLineCount = 3
+
+// ReelsetRTP holds the exact figures of a single reelset.
+// Awards are measured in wagers.
+type ReelsetRTP struct {
+	Name         string
+	Weight       int
+	RTP          float64 // expected line award of a spin
+	HitFrequency float64 // probability of a spin with a line award
+	Variance     float64 // variance of the line award of a spin
+	BonusCounts  []float64
+	ExpectedRTP  float64 // RTP from the game definition
+}
+
+// RTPReport holds the exact figures of a game computed by CalculateRTP
+type RTPReport struct {
+	Game     string
+	Reelsets []ReelsetRTP
+
+	BaseRTP      float64 // line awards weighted over reelsets
+	HitFrequency float64
+	Variance     float64
+
+	FreeSpinsTriggerProbability float64
+	FreeSpinsPerSpin            float64 // expected free spins played per base spin
+	FreeSpinsRTP                float64
+	TotalRTP                    float64 // base and free spins
+
+	ExpectedBaseRTP float64 // TotalRTP from the game definition
+}
+
+// reelColumn is a distinct visible column of a reel after the wild features
+type reelColumn struct {
+	symbols     []Symbol
+	multipliers []int // of the wild cells, 0 for other cells
+	probability float64
+	bonusCount  int
+}
+
+// CalculateRTP computes the RTP, hit frequency and variance of the game by
+// enumerating every stop combination of each reelset instead of sampling.
+// Expanding wilds and wild multipliers are applied per column, random wild reels by enumerating
+// every set of reels they can pick. Sticky wilds link the free spins of a
+// round, games using them in free spins have to be simulated, as do games
+// with cascades or cluster pays.
+func CalculateRTP(game *Game) (*RTPReport, error) {
+	if len(game.Reelsets) == 0 {
+		return nil, errors.New("game has no reelsets")
+	}
+
+	report := &RTPReport{
+		Game:            game.Name,
+		ExpectedBaseRTP: game.TotalRTP,
+	}
+
+	totalWeight := 0
+	for _, data := range game.ReelsetData {
+		totalWeight += data.Weight
+	}
+
+	if totalWeight <= 0 {
+		return nil, errors.New("reelset weights must sum to a positive value")
+	}
+
+	if game.Cascade != nil {
+		return nil, errors.New("cascades refill the window from the reel strips, their RTP can not be enumerated")
+	}
+
+	if game.Evaluation == ClusterEvaluation {
+		return nil, errors.New("clusters span the whole window, their RTP can not be enumerated")
+	}
+
+	for i, payline := range game.Paylines {
+		if game.Evaluation == WaysEvaluation {
+			break
+		}
+
+		for col, pos := range payline {
+			if pos.Col != col {
+				return nil, fmt.Errorf("payline %d: position %d is on reel %d, paylines must go left to right", i, col, pos.Col)
+			}
+			if height := game.Shape.minHeight(col); pos.Row >= height {
+				return nil, fmt.Errorf("payline %d: row %d is outside reel %d, which can show %d rows", i, pos.Row, col, height)
+			}
+		}
+	}
+
+	if len(game.BonusFreeSpins) > 0 {
+		for _, data := range game.ReelsetData {
+			if data.Wilds.Sticky {
+				return nil, fmt.Errorf("reelset %s: sticky wilds link the free spins of a round, their RTP can not be enumerated", data.Name)
+			}
+		}
+	}
+
+	secondMoment := 0.0
+	triggerByCount := make(map[int]float64)
+
+	for i, reels := range game.Reelsets {
+		data := game.ReelsetData[i]
+		rs := calculateReelsetRTP(game, reels, data)
+		report.Reelsets = append(report.Reelsets, rs)
+
+		weight := float64(data.Weight) / float64(totalWeight)
+		report.BaseRTP += weight * rs.RTP
+		report.HitFrequency += weight * rs.HitFrequency
+		secondMoment += weight * (rs.Variance + rs.RTP*rs.RTP)
+
+		for count, probability := range rs.BonusCounts {
+			if freeSpins := game.freeSpinsForBonusCount(count); freeSpins > 0 {
+				triggerByCount[freeSpins] += weight * probability
+				report.FreeSpinsTriggerProbability += weight * probability
+			}
+		}
+	}
+
+	report.Variance = secondMoment - report.BaseRTP*report.BaseRTP
+
+	// Free spins use the same reelset selection, so every free spin has the
+	// base game RTP and the round length does not depend on its own awards
+	expectedLength := freeSpinsRoundLength(triggerByCount, game.MaxFreeSpins)
+	for freeSpins, probability := range triggerByCount {
+		report.FreeSpinsPerSpin += probability * expectedLength(min(freeSpins, game.MaxFreeSpins), 0)
+	}
+
+	report.FreeSpinsRTP = report.BaseRTP * report.FreeSpinsPerSpin
+	report.TotalRTP = report.BaseRTP + report.FreeSpinsRTP
+
+	return report, nil
+}
+
+// Verify compares the computed base game figures with the RTP values of the
+// game definition and returns an error listing every disagreement
+func (r *RTPReport) Verify(tolerance float64) error {
+	var errs []error
+
+	for _, rs := range r.Reelsets {
+		if math.Abs(rs.RTP-rs.ExpectedRTP) > tolerance {
+			errs = append(errs, fmt.Errorf("reelset %s: computed RTP %.10f, definition has %.10f", rs.Name, rs.RTP, rs.ExpectedRTP))
+		}
+	}
+
+	if math.Abs(r.BaseRTP-r.ExpectedBaseRTP) > tolerance {
+		errs = append(errs, fmt.Errorf("game %s: computed base RTP %.10f, definition has %.10f", r.Game, r.BaseRTP, r.ExpectedBaseRTP))
+	}
+
+	return errors.Join(errs...)
+}
+
+func calculateReelsetRTP(game *Game, reels *Reels, data ReelsetData) ReelsetRTP {
+	// Visible columns of every reel as it lands and as a random wild reel
+	landed := make([][]reelColumn, len(reels.Reels))
+	wild := make([][]reelColumn, len(reels.Reels))
+	for i, reel := range reels.Reels {
+		heights := game.Shape.heightProbabilities(i)
+		for _, height := range slices.Sorted(maps.Keys(heights)) {
+			landed[i] = append(landed[i], reelColumns(reel, height, heights[height], data.Wilds, false)...)
+			wild[i] = append(wild[i], reelColumns(reel, height, heights[height], data.Wilds, true)...)
+		}
+	}
+
+	rs := ReelsetRTP{
+		Name:        data.Name,
+		Weight:      data.Weight,
+		ExpectedRTP: data.RTP,
+	}
+
+	for _, set := range wildReelSets(len(reels.Reels), data.Wilds.WildReels) {
+		columns := make([][]reelColumn, len(reels.Reels))
+		for i := range columns {
+			columns[i] = landed[i]
+			if set.reels&(1<<i) != 0 {
+				columns[i] = wild[i]
+			}
+		}
+
+		for count, probability := range bonusCountDistribution(columns) {
+			for len(rs.BonusCounts) <= count {
+				rs.BonusCounts = append(rs.BonusCounts, 0)
+			}
+			rs.BonusCounts[count] += set.probability * probability
+		}
+
+		enumerate := enumerateColumns
+		if game.Evaluation == WaysEvaluation {
+			enumerate = enumerateWays
+		}
+
+		mean, squares, hits := enumerate(game, columns)
+		rs.RTP += set.probability * mean
+		rs.Variance += set.probability * squares
+		rs.HitFrequency += set.probability * hits
+	}
+
+	rs.Variance -= rs.RTP * rs.RTP
+
+	return rs
+}
+
+// wildReelSet is a set of reels turned wild, as a bit mask, and its probability
+type wildReelSet struct {
+	reels       uint64
+	probability float64
+}
+
+// wildReelSets returns every set of reels the random wild reels can pick.
+// Sets of the same size are equally likely.
+func wildReelSets(width int, weights []int) []wildReelSet {
+	total := 0
+	for _, weight := range weights {
+		total += weight
+	}
+
+	if total == 0 {
+		return []wildReelSet{{probability: 1}}
+	}
+
+	var sets []wildReelSet
+
+	for count, weight := range weights {
+		if weight == 0 {
+			continue
+		}
+
+		// Number of sets of count reels
+		combinations := 1.0
+		for i := 0; i < count; i++ {
+			combinations = combinations * float64(width-i) / float64(i+1)
+		}
+
+		probability := float64(weight) / float64(total) / combinations
+
+		for mask := uint64(0); mask < 1<<width; mask++ {
+			if bits.OnesCount64(mask) == count {
+				sets = append(sets, wildReelSet{reels: mask, probability: probability})
+			}
+		}
+	}
+
+	return sets
+}
+
+// enumerateColumns returns the expected award, its second moment and the hit
+// frequency of the window built from the columns of every reel
+func enumerateColumns(game *Game, columns [][]reelColumn) (mean, squares, hits float64) {
+	if len(columns) < minLineCount {
+		return 0, 0, 0
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		firstCh = make(chan int)
+	)
+
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			e := &rtpEnumerator{
+				game:    game,
+				columns: columns,
+				window:  make([]*reelColumn, minLineCount),
+			}
+
+			for first := range firstCh {
+				e.window[0] = &columns[0][first]
+				e.enumerate(1, columns[0][first].probability)
+			}
+
+			mu.Lock()
+			mean += e.mean
+			squares += e.squares
+			hits += e.hits
+			mu.Unlock()
+		}()
+	}
+
+	for first := range columns[0] {
+		firstCh <- first
+	}
+	close(firstCh)
+	wg.Wait()
+
+	return mean, squares, hits
+}
+
+// lineState is a payline evaluated up to some reel the same way as evaluateLine
+type lineState struct {
+	payline    []Position
+	target     Symbol // None while the line only holds wilds
+	count      int
+	multiplier int // wild multipliers combined so far
+}
+
+func newLineState(payline []Position, combine MultiplierCombine) lineState {
+	return lineState{payline: payline, multiplier: combine.start()}
+}
+
+// pay returns the award of the line in percent of the wager
+func (l lineState) pay(game *Game) int64 {
+	target := l.target
+	if target == None {
+		target = Wild
+	}
+
+	if l.count < minLineCount {
+		return 0
+	}
+
+	return game.Paytable[target][l.count] * int64(game.MultiplierCombine.total(l.multiplier))
+}
+
+// next returns the state after the symbol of a cell with the given wild
+// multiplier and whether the line still matches
+func (l lineState) next(symbol Symbol, multiplier int, combine MultiplierCombine) (lineState, bool) {
+	switch {
+	case symbol == Wild:
+		l.multiplier = combine.add(l.multiplier, multiplier)
+	case l.target == None:
+		l.target = symbol
+	case symbol != l.target:
+		return l, false
+	}
+
+	l.count++
+
+	return l, true
+}
+
+// rtpEnumerator walks the stop combinations of one reelset. The first reels
+// are enumerated column by column, the remaining reels only by the way their
+// columns affect the lines still matching at that point.
+type rtpEnumerator struct {
+	game    *Game
+	columns [][]reelColumn
+	window  []*reelColumn
+
+	mean, squares, hits float64
+}
+
+func (e *rtpEnumerator) enumerate(reel int, probability float64) {
+	if reel < minLineCount {
+		for i := range e.columns[reel] {
+			e.window[reel] = &e.columns[reel][i]
+			e.enumerate(reel+1, probability*e.columns[reel][i].probability)
+		}
+		return
+	}
+
+	var lines []lineState
+
+	for _, payline := range e.game.Paylines {
+		line := newLineState(payline, e.game.MultiplierCombine)
+		matches := true
+
+		for _, pos := range payline[:minLineCount] {
+			column := e.window[pos.Col]
+			if line, matches = line.next(column.symbols[pos.Row], column.multipliers[pos.Row], e.game.MultiplierCombine); !matches {
+				break
+			}
+		}
+
+		if !matches {
+			continue
+		}
+
+		if _, ok := e.game.Paytable[line.target]; ok || line.target == None {
+			lines = append(lines, line)
+		}
+	}
+
+	if len(lines) > 0 {
+		e.suffix(reel, lines, 0, probability)
+	}
+}
+
+// columnGroup is a set of columns of a reel with the same effect on the matching lines
+type columnGroup struct {
+	key         []byte
+	probability float64
+	column      *reelColumn
+}
+
+func (e *rtpEnumerator) suffix(reel int, lines []lineState, settled int64, probability float64) {
+	if reel == len(e.columns) {
+		award := settled
+		for _, line := range lines {
+			award += line.pay(e.game)
+		}
+
+		if award > 0 {
+			value := float64(award) / 100
+			e.mean += probability * value
+			e.squares += probability * value * value
+			e.hits += probability
+		}
+		return
+	}
+
+	var groups []columnGroup
+
+	for i := range e.columns[reel] {
+		column := &e.columns[reel][i]
+
+		// Per line the symbol, or only whether it matches, and the wild multiplier
+		key := make([]byte, 0, 2*len(lines))
+		for _, line := range lines {
+			row := line.payline[reel].Row
+			symbol := column.symbols[row]
+			switch {
+			case line.target == None:
+				key = append(key, byte(symbol))
+			case symbol == line.target || symbol == Wild:
+				key = append(key, 1)
+			default:
+				key = append(key, 0)
+			}
+			key = binary.AppendUvarint(key, uint64(column.multipliers[row]))
+		}
+
+		found := false
+		for g := range groups {
+			if string(groups[g].key) == string(key) {
+				groups[g].probability += column.probability
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			groups = append(groups, columnGroup{key: key, probability: column.probability, column: column})
+		}
+	}
+
+	for _, group := range groups {
+		next := make([]lineState, 0, len(lines))
+		nextSettled := settled
+
+		for _, line := range lines {
+			row := line.payline[reel].Row
+			if line, matches := line.next(group.column.symbols[row], group.column.multipliers[row], e.game.MultiplierCombine); matches {
+				next = append(next, line)
+			} else {
+				nextSettled += line.pay(e.game)
+			}
+		}
+
+		e.suffix(reel+1, next, nextSettled, probability*group.probability)
+	}
+}
+
+// waysSymbol is a symbol still matching on every reel so far, evaluated the
+// same way as WaysEvaluator
+type waysSymbol struct {
+	symbol Symbol
+	count  int
+	ways   map[int]int64 // by the combined multiplier of their wilds
+}
+
+// pay returns the award of the ways of the symbol in percent of the wager
+func (w waysSymbol) pay(game *Game) int64 {
+	pay, ok := game.Paytable[w.symbol][w.count]
+	if w.count < minLineCount || !ok {
+		return 0
+	}
+
+	award := int64(0)
+	for combined, n := range w.ways {
+		award += pay * n * int64(game.MultiplierCombine.total(combined))
+	}
+
+	return award
+}
+
+// waysState is a set of windows enumerated up to some reel with the same
+// settled award and the same ways of the symbols still matching
+type waysState struct {
+	settled     int64
+	symbols     []waysSymbol
+	probability float64
+}
+
+// key identifies states that evaluate the remaining reels the same way
+func (s *waysState) key() string {
+	key := binary.AppendVarint(nil, s.settled)
+	for _, symbol := range s.symbols {
+		key = binary.AppendUvarint(key, uint64(symbol.symbol))
+		key = binary.AppendUvarint(key, uint64(symbol.count))
+		for _, combined := range slices.Sorted(maps.Keys(symbol.ways)) {
+			key = binary.AppendUvarint(key, uint64(combined))
+			key = binary.AppendVarint(key, symbol.ways[combined])
+		}
+		key = append(key, 0xff)
+	}
+
+	return string(key)
+}
+
+// enumerateWays is enumerateColumns for ways evaluation. The reels are
+// walked left to right, merging windows whose ways continue alike.
+func enumerateWays(game *Game, columns [][]reelColumn) (mean, squares, hits float64) {
+	start := &waysState{probability: 1}
+	for _, symbol := range slices.Sorted(maps.Keys(game.Paytable)) {
+		if symbol != Wild {
+			start.symbols = append(start.symbols, waysSymbol{
+				symbol: symbol,
+				ways:   map[int]int64{game.MultiplierCombine.start(): 1},
+			})
+		}
+	}
+
+	awards := make(map[int64]float64)
+	states := []*waysState{start}
+
+	for reel := 0; reel <= len(columns) && len(states) > 0; reel++ {
+		index := make(map[string]*waysState)
+		var next []*waysState
+
+		for _, state := range states {
+			if reel == len(columns) {
+				award := state.settled
+				for _, symbol := range state.symbols {
+					award += symbol.pay(game)
+				}
+				awards[award] += state.probability
+				continue
+			}
+
+			for i := range columns[reel] {
+				landed := nextWaysState(game, state, &columns[reel][i])
+
+				if len(landed.symbols) == 0 {
+					awards[landed.settled] += landed.probability
+					continue
+				}
+
+				key := landed.key()
+				if merged, ok := index[key]; ok {
+					merged.probability += landed.probability
+					continue
+				}
+
+				index[key] = landed
+				next = append(next, landed)
+			}
+		}
+
+		states = next
+	}
+
+	for award, probability := range awards {
+		if award > 0 {
+			value := float64(award) / 100
+			mean += probability * value
+			squares += probability * value * value
+			hits += probability
+		}
+	}
+
+	return mean, squares, hits
+}
+
+// nextWaysState returns the state after the column landed on the next reel
+func nextWaysState(game *Game, state *waysState, column *reelColumn) *waysState {
+	next := &waysState{
+		settled:     state.settled,
+		probability: state.probability * column.probability,
+	}
+
+	for _, symbol := range state.symbols {
+		ways := make(map[int]int64)
+		for row, cell := range column.symbols {
+			if cell != symbol.symbol && cell != Wild {
+				continue
+			}
+
+			for combined, n := range symbol.ways {
+				ways[game.MultiplierCombine.add(combined, column.multipliers[row])] += n
+			}
+		}
+
+		if len(ways) == 0 {
+			next.settled += symbol.pay(game)
+			continue
+		}
+
+		next.symbols = append(next.symbols, waysSymbol{symbol: symbol.symbol, count: symbol.count + 1, ways: ways})
+	}
+
+	return next
+}
+
+// reelColumns returns the distinct visible columns of a reel showing height
+// rows with their probabilities, scaled by the probability of the height.
+// Like applyWilds, a wild reel and an expanding wild turn every cell but
+// Bonus symbols into Wild, every wild cell then carries each of the wild
+// multipliers with its weight.
+func reelColumns(reel []Symbol, height int, heightProbability float64, wilds Wilds, wildReel bool) []reelColumn {
+	index := make(map[string]int)
+	var columns []reelColumn
+
+	add := func(symbols []Symbol, multipliers []int, probability float64) {
+		if probability == 0 {
+			return
+		}
+
+		key := fmt.Sprint(symbols, multipliers)
+		if i, ok := index[key]; ok {
+			columns[i].probability += probability
+			return
+		}
+
+		column := reelColumn{
+			symbols:     append([]Symbol{}, symbols...),
+			multipliers: append([]int{}, multipliers...),
+			probability: probability,
+		}
+		for _, symbol := range symbols {
+			if symbol == Bonus {
+				column.bonusCount++
+			}
+		}
+
+		index[key] = len(columns)
+		columns = append(columns, column)
+	}
+
+	totalWeight := float64(wilds.multiplierWeight())
+
+	var multiply func(symbols []Symbol, multipliers []int, row int, probability float64)
+	multiply = func(symbols []Symbol, multipliers []int, row int, probability float64) {
+		if row == len(symbols) {
+			add(symbols, multipliers, probability)
+			return
+		}
+
+		if symbols[row] != Wild || len(wilds.Multipliers) == 0 {
+			multiply(symbols, multipliers, row+1, probability)
+			return
+		}
+
+		for _, multiplier := range wilds.Multipliers {
+			multipliers[row] = multiplier.Value
+			multiply(symbols, multipliers, row+1, probability*float64(multiplier.Weight)/totalWeight)
+		}
+		multipliers[row] = 0
+	}
+
+	for stop := range reel {
+		symbols := make([]Symbol, height)
+		for row := range symbols {
+			symbols[row] = reel[(stop+row)%len(reel)]
+		}
+
+		if wildReel || wilds.Expanding && slices.Contains(symbols, Wild) {
+			for row, symbol := range symbols {
+				if symbol != Bonus {
+					symbols[row] = Wild
+				}
+			}
+		}
+
+		multiply(symbols, make([]int, len(symbols)), 0, heightProbability/float64(len(reel)))
+	}
+
+	return columns
+}
+
+// bonusCountDistribution returns the probability of every number of Bonus symbols in the window
+func bonusCountDistribution(columns [][]reelColumn) []float64 {
+	distribution := []float64{1}
+
+	for _, reel := range columns {
+		height := 0
+		for _, column := range reel {
+			height = max(height, len(column.symbols))
+		}
+
+		next := make([]float64, len(distribution)+height)
+		for count, probability := range distribution {
+			for _, column := range reel {
+				next[count+column.bonusCount] += probability * column.probability
+			}
+		}
+		distribution = next
+	}
+
+	return distribution
+}
+
+// freeSpinsRoundLength returns the expected number of spins of a free spins
+// round, given the awarded spins and the spins already played. Retriggers
+// follow triggerByCount and the round is capped like in playFreeSpins.
+func freeSpinsRoundLength(triggerByCount map[int]float64, maxFreeSpins int) func(count, played int) float64 {
+	noTrigger := 1.0
+	for _, probability := range triggerByCount {
+		noTrigger -= probability
+	}
+
+	type state struct{ count, played int }
+	memo := make(map[state]float64)
+
+	var length func(count, played int) float64
+	length = func(count, played int) float64 {
+		if played >= count {
+			return float64(played)
+		}
+
+		if value, ok := memo[state{count, played}]; ok {
+			return value
+		}
+
+		value := noTrigger * length(count, played+1)
+		for freeSpins, probability := range triggerByCount {
+			value += probability * length(min(count+freeSpins, maxFreeSpins), played+1)
+		}
+
+		memo[state{count, played}] = value
+
+		return value
+	}
+
+	return length
+}
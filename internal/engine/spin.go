@@ -30,9 +30,11 @@ func NewWindow(width, height int) *Window {
 
 // SpinFactory handles creating spins
 type SpinFactory struct {
-	reels    *Reels
-	rng      RNG
-	reelsets []*Reels
+	reels     *Reels
+	rng       RNG
+	reelsets  []*Reels
+	game      *Game
+	evaluator Evaluator
 }
 
 // NewSpinFactory creates a new spin factory
@@ -47,101 +49,240 @@ func NewSpinFactory(reels *Reels, rng RNG) *SpinFactory {
 	}
 }
 
+// NewSpinFactoryFromGame creates a spin factory for the given game definition
+func NewSpinFactoryFromGame(game *Game, rng RNG) *SpinFactory {
+	return &SpinFactory{
+		reels:     game.Reelsets[0],
+		rng:       rng,
+		reelsets:  game.Reelsets,
+		game:      game,
+		evaluator: NewEvaluator(game.Evaluation),
+	}
+}
+
+// Game returns the game the factory spins, falling back to the built-in game
+func (s *SpinFactory) Game() *Game {
+	if s.game != nil {
+		return s.game
+	}
+
+	return DefaultGame()
+}
+
+// Evaluator returns the evaluator finding the wins of a window, by default
+// the one selected by the evaluation mode of the game
+func (s *SpinFactory) Evaluator() Evaluator {
+	if s.evaluator != nil {
+		return s.evaluator
+	}
+
+	return NewEvaluator(s.Game().Evaluation)
+}
+
+// WithEvaluator returns a copy of the factory finding wins with evaluator
+func (s *SpinFactory) WithEvaluator(evaluator Evaluator) *SpinFactory {
+	factory := *s
+	factory.evaluator = evaluator
+
+	return &factory
+}
+
 // Generate creates a new spin
 func (s *SpinFactory) Generate(wager int64) (*Spin, error) {
 	if wager <= 0 {
 		return nil, fmt.Errorf("wager must be positive")
 	}
 
+	game := s.Game()
+
+	landed, err := s.spinReels(game, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Calculate award, cascading when the game has cascades
+	award, lineWins, cascades := s.evaluate(game, landed, wager)
+
+	spin := &Spin{
+		Window:       landed.window,
+		Reelset:      landed.reelset,
+		Stops:        landed.stops,
+		WildCells:    landed.wildCells,
+		Wager:        wager,
+		Award:        award,
+		BaseAwardVal: award,
+		LineWins:     lineWins,
+		Cascades:     cascades,
+	}
+
+	if freeSpins := game.freeSpinsForBonusCount(countBonus(landed.window)); freeSpins > 0 {
+		if err := s.playFreeSpins(game, spin, freeSpins); err != nil {
+			return nil, err
+		}
+	}
+
+	return spin, nil
+}
+
+// landing is the outcome of spinning the reels once
+type landing struct {
+	window    *Window
+	reelset   int
+	stops     []int
+	wildCells []WildCell
+}
+
+// spinReels selects a reelset, stops the reels, builds the visible window and
+// applies the wild features. sticky holds the sticky wilds of a free spins
+// round, it is nil in the base game.
+func (s *SpinFactory) spinReels(game *Game, sticky [][]bool) (*landing, error) {
 	// Select a reelset based on weights
-	selectedReels, reelsetIndex, err := selectReelset(s.rng)
+	selectedReels, reelsetIndex, err := selectReelset(s.rng, game)
 	if err != nil {
 		return nil, fmt.Errorf("failed to select reelset: %w", err)
 	}
 
-	// Generate random stops
-	stops := make([]int, len(selectedReels.Reels))
+	var wilds Wilds
+	if reelsetIndex >= 0 && reelsetIndex < len(game.ReelsetData) {
+		wilds = game.ReelsetData[reelsetIndex].Wilds
+	}
+
+	width := len(selectedReels.Reels)
+
+	// Draw every stop, the random reel heights, the random wild reels and,
+	// when wilds carry multipliers, a multiplier roll for every cell a reel
+	// can show in one batch. Rolls of cells that are not shown or do not end
+	// up wild are unused.
+	rows := game.Shape.rows(width)
+	heightDraws := game.Shape.heightDraws(width)
+	wildReelDraws := wilds.wildReelDraws(width)
+	multiplierDraws := wilds.multiplierDraws(width * rows)
+
+	maxSlice := make([]uint64, 0, width+len(heightDraws)+len(wildReelDraws)+len(multiplierDraws))
+	for _, reel := range selectedReels.Reels {
+		maxSlice = append(maxSlice, uint64(len(reel)))
+	}
+	maxSlice = append(maxSlice, heightDraws...)
+	maxSlice = append(maxSlice, wildReelDraws...)
+	maxSlice = append(maxSlice, multiplierDraws...)
+
+	draws, err := s.rng.RandSlice(maxSlice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate random number: %w", err)
+	}
+	if len(draws) != len(maxSlice) {
+		return nil, fmt.Errorf("RNG returned %d random numbers, requested %d", len(draws), len(maxSlice))
+	}
+
+	stops := make([]int, width)
 	for i := range stops {
-		val, err := s.rng.Rand(uint64(len(selectedReels.Reels[i])))
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate random number: %w", err)
-		}
-		stops[i] = int(val)
+		stops[i] = int(draws[i])
 	}
 
-	// Create window from stops
-	window := NewWindow(len(selectedReels.Reels), 3)
+	draws = draws[width:]
+	heights := game.Shape.heights(width, draws[:len(heightDraws)])
+	draws = draws[len(heightDraws):]
+
+	// Create window from stops with the rows of every reel
+	window := &Window{Symbols: make([][]Symbol, width)}
 	for i, stop := range stops {
-		for j := 0; j < 3; j++ {
+		window.Symbols[i] = make([]Symbol, heights[i])
+		for j := 0; j < heights[i]; j++ {
 			symbolIndex := (stop + j) % len(selectedReels.Reels[i])
 			window.Symbols[i][j] = selectedReels.Reels[i][symbolIndex]
 		}
 	}
 
-	if reelsetIndex >= 0 && reelsetIndex < len(AllReelsetData) {
-		reelsetData := AllReelsetData[reelsetIndex]
-		if reelsetData.WildsProbability > 0 {
-			// Для каждой позиции проверяем шанс замены на дикий символ
-			for i := range window.Symbols {
-				for j := range window.Symbols[i] {
-					// Пропускаем, если уже дикий символ или бонусный символ
-					if window.Symbols[i][j] == Wild || window.Symbols[i][j] == Bonus {
-						continue
-					}
-
-					// Проверяем шанс замены на дикий символ
-					wildChance, err := s.rng.Rand(100)
-					if err == nil && float64(wildChance)/100.0 < reelsetData.WildsProbability {
-						window.Symbols[i][j] = Wild
-					}
-				}
-			}
-		}
-	}
-
-	// Calculate award
-	award := s.calculateAward(window, wager)
+	wildReels := wilds.pickWildReels(width, draws[:len(wildReelDraws)])
+	wildCells := applyWilds(window, wilds, wildReels, sticky)
+	wilds.assignMultipliers(window, rows, draws[len(wildReelDraws):])
 
-	return &Spin{
-		Window:       window,
-		Stops:        stops,
-		Wager:        wager,
-		Award:        award,
-		BaseAwardVal: award,
+	return &landing{
+		window:    window,
+		reelset:   reelsetIndex,
+		stops:     stops,
+		wildCells: wildCells,
 	}, nil
 }
 
-// calculateAward calculates the award for a window
-func (s *SpinFactory) calculateAward(window *Window, wager int64) int64 {
-	return s.calculateAwardWithPaylines(window, wager)
+// playFreeSpins plays the free spins round triggered by the base spin.
+// Free spins use the same reelset selection as the base game, can retrigger
+// and are capped at the game's MaxFreeSpins, the initial award included.
+// Sticky wilds hold for the whole round. Their total is recorded as the
+// bonus award.
+func (s *SpinFactory) playFreeSpins(game *Game, spin *Spin, count int) error {
+	count = min(count, game.MaxFreeSpins)
+
+	width := len(spin.Window.Symbols)
+	sticky := newStickyCells(width, game.Shape.rows(width))
+
+	for played := 0; played < count; played++ {
+		landed, err := s.spinReels(game, sticky)
+		if err != nil {
+			return fmt.Errorf("failed to play free spin: %w", err)
+		}
+
+		award, lineWins, cascades := s.evaluate(game, landed, spin.Wager)
+
+		spin.FreeSpins = append(spin.FreeSpins, &FreeSpin{
+			Window:    landed.window,
+			Reelset:   landed.reelset,
+			Stops:     landed.stops,
+			WildCells: landed.wildCells,
+			Award:     award,
+			LineWins:  lineWins,
+			Cascades:  cascades,
+		})
+		spin.BonusAwardVal += award
+
+		count = min(count+game.freeSpinsForBonusCount(countBonus(landed.window)), game.MaxFreeSpins)
+	}
+
+	spin.Award = spin.BaseAwardVal + spin.BonusAwardVal
+
+	return nil
 }
 
-// calculateAwardWithPaylines calculates the award based on paylines
-func (s *SpinFactory) calculateAwardWithPaylines(window *Window, wager int64) int64 {
-	totalAward := int64(0)
-
-	// Check each payline
-	for _, payline := range Paylines {
-		// Get symbols on this payline
-		symbols := make([]Symbol, len(payline))
-		for i, pos := range payline {
-			if pos.Col < len(window.Symbols) && pos.Row < len(window.Symbols[pos.Col]) {
-				symbols[i] = window.Symbols[pos.Col][pos.Row]
+// countBonus counts Bonus symbols anywhere in the window
+func countBonus(window *Window) int {
+	count := 0
+	for _, col := range window.Symbols {
+		for _, symbol := range col {
+			if symbol == Bonus {
+				count++
 			}
 		}
-
-		// Count consecutive symbols from left to right
-		award := s.evaluateSymbolLine(symbols, wager)
-		totalAward += award
 	}
 
-	return totalAward
+	return count
+}
+
+// calculateAward calculates the award for a window with the factory's evaluator
+func (s *SpinFactory) calculateAward(game *Game, window *Window, wager int64) (int64, []LineWin) {
+	return s.Evaluator().Evaluate(game, window, wager)
+}
+
+// calculateAwardWithPaylines calculates the award based on paylines
+// and returns the wins of every paying line
+func (s *SpinFactory) calculateAwardWithPaylines(game *Game, window *Window, wager int64) (int64, []LineWin) {
+	return PaylineEvaluator{}.Evaluate(game, window, wager)
 }
 
 // evaluateSymbolLine evaluates a line of symbols for wins
 func (s *SpinFactory) evaluateSymbolLine(symbols []Symbol, wager int64) int64 {
+	game := s.Game()
+	_, _, _, award := evaluateLine(game.Paytable, symbols, nil, game.MultiplierCombine, wager)
+	return award
+}
+
+// evaluateLine evaluates a line of symbols against the pay table and returns
+// the paying symbol, the number of consecutive matches from the left, the
+// combined multiplier of the wilds among them and the award. multipliers
+// holds the multiplier of every wild on the line, it may be nil.
+// Lines of only wilds pay the Wild entry of the pay table.
+func evaluateLine(paytable map[Symbol]map[int]int64, symbols []Symbol, multipliers []int, combine MultiplierCombine, wager int64) (Symbol, int, int, int64) {
 	if len(symbols) == 0 {
-		return 0
+		return None, 0, 1, 0
 	}
 
 	// Find the first non-wild symbol (if any)
@@ -160,58 +301,56 @@ func (s *SpinFactory) evaluateSymbolLine(symbols []Symbol, wager int64) int64 {
 
 	// Count consecutive matching symbols from left
 	count := 0
+	multiplier := combine.start()
 	for i := 0; i < len(symbols); i++ {
-		if symbols[i] == targetSymbol || symbols[i] == Wild {
-			count++
-		} else {
+		if symbols[i] != targetSymbol && symbols[i] != Wild {
 			break
 		}
+
+		count++
+		if symbols[i] == Wild && i < len(multipliers) {
+			multiplier = combine.add(multiplier, multipliers[i])
+		}
 	}
+	multiplier = combine.total(multiplier)
 
 	// If we have at least 3 matching symbols, calculate win
 	if count >= 3 {
-		if multipliers, ok := symbolMultipliers[targetSymbol]; ok {
-			if multiplier, ok := multipliers[count]; ok {
-				return multiplier * wager / 100
+		if pays, ok := paytable[targetSymbol]; ok {
+			if pay, ok := pays[count]; ok {
+				return targetSymbol, count, multiplier, pay * int64(multiplier) * wager / 100
 			}
 		}
 	}
 
-	return 0
+	return targetSymbol, count, multiplier, 0
 }
 
-// Weights for selecting reelsets - moved to static.go as ReelsetWeights
-// var reelsetWeights = []int{85, 7, 2, 6}
-
-// selectReelset selects a reelset based on weights from pick-probabilities.csv
+// selectReelset selects a reelset of the game based on the reelset weights
 // Returns the selected reelset and its index
-func selectReelset(rng RNG) (*Reels, int, error) {
-	// Get a random number from 0 to 99
-	val, err := rng.Rand(100)
+func selectReelset(rng RNG, game *Game) (*Reels, int, error) {
+	totalWeight := 0
+	for _, data := range game.ReelsetData {
+		totalWeight += data.Weight
+	}
+
+	// Get a random number from 0 to the total weight
+	val, err := rng.Rand(uint64(totalWeight))
 	if err != nil {
 		return nil, -1, err
 	}
 
 	// Select based on weights
 	cumulativeWeight := uint64(0)
-	for i, weight := range ReelsetWeights {
-		cumulativeWeight += uint64(weight)
+	for i, data := range game.ReelsetData {
+		cumulativeWeight += uint64(data.Weight)
 		if val < cumulativeWeight {
-			switch i {
-			case 0:
-				return reel1, 0, nil
-			case 1:
-				return reel2, 1, nil
-			case 2:
-				return reel3, 2, nil
-			case 3:
-				return reel4, 3, nil
-			}
+			return game.Reelsets[i], i, nil
 		}
 	}
 
 	// Default to the first reelset
-	return reel1, 0, nil
+	return game.Reelsets[0], 0, nil
 }
 
 // Implement the Spin interface
@@ -220,7 +359,7 @@ func (s *Spin) BaseAward() int64 {
 }
 
 func (s *Spin) BonusAward() int64 {
-	return 0 // No bonus in this simple implementation
+	return s.BonusAwardVal
 }
 
 func (s *Spin) GetWager() int64 {
@@ -232,7 +371,7 @@ func (s *Spin) OriginalWager() int64 {
 }
 
 func (s *Spin) BonusTriggered() bool {
-	return false // No bonus in this simple implementation
+	return len(s.FreeSpins) > 0
 }
 
 func (s *Spin) DeepCopy() interface{} {
@@ -240,10 +379,12 @@ func (s *Spin) DeepCopy() interface{} {
 		Window: &Window{
 			Symbols: make([][]Symbol, len(s.Window.Symbols)),
 		},
-		Stops:        make([]int, len(s.Stops)),
-		Wager:        s.Wager,
-		Award:        s.Award,
-		BaseAwardVal: s.BaseAwardVal,
+		Reelset:       s.Reelset,
+		Stops:         make([]int, len(s.Stops)),
+		Wager:         s.Wager,
+		Award:         s.Award,
+		BaseAwardVal:  s.BaseAwardVal,
+		BonusAwardVal: s.BonusAwardVal,
 	}
 
 	for i, stop := range s.Stops {
@@ -254,10 +395,64 @@ func (s *Spin) DeepCopy() interface{} {
 		newSpin.Window.Symbols[i] = make([]Symbol, len(col))
 		copy(newSpin.Window.Symbols[i], col)
 	}
+	newSpin.Window.Multipliers = copyMultipliers(s.Window.Multipliers)
+
+	newSpin.LineWins = copyLineWins(s.LineWins)
+	newSpin.WildCells = copyWildCells(s.WildCells)
+	newSpin.Cascades = copyCascadeSteps(s.Cascades)
+
+	if s.Draws != nil {
+		newSpin.Draws = make([]Draw, len(s.Draws))
+		copy(newSpin.Draws, s.Draws)
+	}
+
+	for _, freeSpin := range s.FreeSpins {
+		newSpin.FreeSpins = append(newSpin.FreeSpins, freeSpin.deepCopy())
+	}
 
 	return newSpin
 }
 
+func (f *FreeSpin) deepCopy() *FreeSpin {
+	newFreeSpin := &FreeSpin{
+		Window: &Window{
+			Symbols: make([][]Symbol, len(f.Window.Symbols)),
+		},
+		Reelset: f.Reelset,
+		Stops:   make([]int, len(f.Stops)),
+		Award:   f.Award,
+	}
+
+	copy(newFreeSpin.Stops, f.Stops)
+
+	for i, col := range f.Window.Symbols {
+		newFreeSpin.Window.Symbols[i] = make([]Symbol, len(col))
+		copy(newFreeSpin.Window.Symbols[i], col)
+	}
+	newFreeSpin.Window.Multipliers = copyMultipliers(f.Window.Multipliers)
+
+	newFreeSpin.LineWins = copyLineWins(f.LineWins)
+	newFreeSpin.WildCells = copyWildCells(f.WildCells)
+	newFreeSpin.Cascades = copyCascadeSteps(f.Cascades)
+
+	return newFreeSpin
+}
+
+func copyLineWins(lineWins []LineWin) []LineWin {
+	if lineWins == nil {
+		return nil
+	}
+
+	newLineWins := make([]LineWin, len(lineWins))
+	for i, lineWin := range lineWins {
+		newLineWins[i] = lineWin
+		newLineWins[i].Positions = make([]Position, len(lineWin.Positions))
+		copy(newLineWins[i].Positions, lineWin.Positions)
+	}
+
+	return newLineWins
+}
+
 func (s *Spin) GetGamble() *Gamble {
 	return nil // No gamble in this simple implementation
 }
@@ -298,9 +493,5 @@ func GetAllReelsets() []*Reels {
 }
 
 func NewSpinFactoryWithAllReelsets(rng RNG) *SpinFactory {
-	return &SpinFactory{
-		reels:    reel1,
-		rng:      rng,
-		reelsets: []*Reels{reel1, reel2, reel3, reel4},
-	}
+	return NewSpinFactoryFromGame(DefaultGame(), rng)
 }
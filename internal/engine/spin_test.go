@@ -29,6 +29,16 @@ func (m *MockRNG) Rand(max uint64) (uint64, error) {
 	return val, nil
 }
 
+// RandSlice возвращает следующие предопределенные значения для каждого max
+func (m *MockRNG) RandSlice(maxSlice []uint64) ([]uint64, error) {
+	vals := make([]uint64, len(maxSlice))
+	for i, max := range maxSlice {
+		vals[i], _ = m.Rand(max)
+	}
+
+	return vals, nil
+}
+
 // TestSpinFactoryGenerate тестирует метод Generate
 func TestSpinFactoryGenerate(t *testing.T) {
 	// Создаем тестовые линии выплат
@@ -148,7 +158,7 @@ func TestSpinFactoryGenerate(t *testing.T) {
 				for row := 0; row < 3; row++ {
 					rowStr := ""
 					for col := 0; col < len(spin.Window.Symbols); col++ {
-						rowStr += symbolToString(spin.Window.Symbols[col][row]) + " "
+						rowStr += spin.Window.Symbols[col][row].String() + " "
 					}
 					t.Logf("Row %d: %s", row, rowStr)
 				}
@@ -179,43 +189,14 @@ func TestSpinFactoryGenerate(t *testing.T) {
 	}
 }
 
-// Вспомогательная функция для отображения символов в виде строки
-func symbolToString(symbol Symbol) string {
-	switch symbol {
-	case Dynamite:
-		return "DYNAMITE"
-	case Bat:
-		return "BAT"
-	case Saw:
-		return "SAW"
-	case Hammer:
-		return "HAMMER"
-	case Key:
-		return "KEY"
-	case A:
-		return "A"
-	case K:
-		return "K"
-	case Q:
-		return "Q"
-	case J:
-		return "J"
-	case Bonus:
-		return "BONUS"
-	case Wild:
-		return "WILD"
-	default:
-		return "UNKNOWN"
-	}
-}
-
 // TestEvaluateSymbolLine тестирует расчет выигрыша по линии символов
 func TestEvaluateSymbolLine(t *testing.T) {
 	tests := []struct {
-		name    string
-		symbols []Symbol
-		wager   int64
-		want    int64
+		name     string
+		symbols  []Symbol
+		paytable map[Symbol]map[int]int64
+		wager    int64
+		want     int64
 	}{
 		{
 			name:    "Empty line",
@@ -254,10 +235,17 @@ func TestEvaluateSymbolLine(t *testing.T) {
 			want:    30, // 3 совпадающих символа с учетом Wild
 		},
 		{
-			name:    "All wilds",
+			name:    "All wilds without a Wild pay",
 			symbols: []Symbol{Wild, Wild, Wild, Wild, Wild},
 			wager:   100,
-			want:    0, // нет выигрыша для линии всех Wild (т.к. нет symbolMultipliers для Wild)
+			want:    0, // в таблице выплат игры по умолчанию нет записи Wild
+		},
+		{
+			name:     "All wilds",
+			symbols:  []Symbol{Wild, Wild, Wild, Wild, Wild},
+			paytable: map[Symbol]map[int]int64{Wild: {3: 50, 4: 100, 5: 500}},
+			wager:    100,
+			want:     500, // линия из одних Wild платит по записи Wild в таблице выплат
 		},
 	}
 
@@ -265,6 +253,11 @@ func TestEvaluateSymbolLine(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Создаем фабрику для тестирования
 			factory := &SpinFactory{}
+			if tt.paytable != nil {
+				game := DefaultGame()
+				game.Paytable = tt.paytable
+				factory = NewSpinFactoryFromGame(game, nil)
+			}
 
 			// Вызываем тестируемый метод
 			got := factory.evaluateSymbolLine(tt.symbols, tt.wager)
@@ -276,3 +269,779 @@ func TestEvaluateSymbolLine(t *testing.T) {
 		})
 	}
 }
+
+// TestSpinFactoryBonus тестирует запуск бесплатных вращений символами Bonus
+func TestSpinFactoryBonus(t *testing.T) {
+	originalPaylines := Paylines
+	Paylines = [][]Position{
+		// Средняя строка
+		{{0, 1}, {1, 1}, {2, 1}, {3, 1}, {4, 1}},
+	}
+	defer func() { Paylines = originalPaylines }()
+
+	// Bonus всегда в верхней строке, A - в средней
+	testReels := &Reels{
+		Reels: [][]Symbol{
+			{Bonus, A, K, Q, J},
+			{Bonus, A, K, Q, J},
+			{Bonus, A, K, Q, J},
+			{Bonus, A, K, Q, J},
+			{Bonus, A, K, Q, J},
+		},
+	}
+
+	originalReel1 := reel1
+	defer func() { reel1 = originalReel1 }()
+	reel1 = testReels
+
+	factory := &SpinFactory{
+		reels:    testReels,
+		rng:      NewMockRNG([]uint64{0}),
+		reelsets: []*Reels{testReels, testReels, testReels, testReels},
+	}
+
+	spin, err := factory.Generate(100)
+	if err != nil {
+		t.Fatalf("SpinFactory.Generate() error = %v", err)
+	}
+
+	if !spin.BonusTriggered() {
+		t.Fatal("Spin.BonusTriggered() = false, want true")
+	}
+
+	// 5 символов Bonus в каждом вращении - повторные запуски до лимита
+	if len(spin.FreeSpins) != MaxFreeSpins {
+		t.Errorf("len(Spin.FreeSpins) = %v, want %v", len(spin.FreeSpins), MaxFreeSpins)
+	}
+
+	// 5 символов A на средней линии платят 25% от ставки
+	if spin.BaseAward() != 25 {
+		t.Errorf("Spin.BaseAward() = %v, want 25", spin.BaseAward())
+	}
+
+	if spin.BonusAward() != 25*MaxFreeSpins {
+		t.Errorf("Spin.BonusAward() = %v, want %v", spin.BonusAward(), 25*MaxFreeSpins)
+	}
+
+	if spin.Award != spin.BaseAward()+spin.BonusAward() {
+		t.Errorf("Spin.Award = %v, want %v", spin.Award, spin.BaseAward()+spin.BonusAward())
+	}
+}
+
+// TestFreeSpinsForBonusCount тестирует таблицу бесплатных вращений
+func TestFreeSpinsForBonusCount(t *testing.T) {
+	tests := []struct {
+		count int
+		want  int
+	}{
+		{count: 0, want: 0},
+		{count: 2, want: 0},
+		{count: 3, want: 8},
+		{count: 4, want: 12},
+		{count: 5, want: 15},
+		{count: 9, want: 15},
+	}
+
+	for _, tt := range tests {
+		if got := DefaultGame().freeSpinsForBonusCount(tt.count); got != tt.want {
+			t.Errorf("freeSpinsForBonusCount(%d) = %v, want %v", tt.count, got, tt.want)
+		}
+	}
+}
+
+// TestCalculateAwardWithPaylinesLineWins тестирует разбивку выигрыша по линиям
+func TestCalculateAwardWithPaylinesLineWins(t *testing.T) {
+	originalPaylines := Paylines
+	Paylines = [][]Position{
+		// Средняя строка
+		{{0, 1}, {1, 1}, {2, 1}, {3, 1}, {4, 1}},
+		// Верхняя строка
+		{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}},
+	}
+	defer func() { Paylines = originalPaylines }()
+
+	window := &Window{
+		Symbols: [][]Symbol{
+			{J, Dynamite, K},
+			{Q, Wild, K},
+			{K, Dynamite, K},
+			{A, Dynamite, K},
+			{J, Key, K},
+		},
+	}
+
+	factory := &SpinFactory{}
+	award, lineWins := factory.calculateAwardWithPaylines(factory.Game(), window, 100)
+
+	if award != 60 {
+		t.Errorf("award = %v, want 60", award)
+	}
+
+	if len(lineWins) != 1 {
+		t.Fatalf("len(lineWins) = %v, want 1", len(lineWins))
+	}
+
+	want := LineWin{
+		Payline:   0,
+		Symbol:    Dynamite,
+		Count:     4,
+		Positions: []Position{{0, 1}, {1, 1}, {2, 1}, {3, 1}},
+		Award:     60,
+	}
+
+	got := lineWins[0]
+	if got.Payline != want.Payline || got.Symbol != want.Symbol || got.Count != want.Count || got.Award != want.Award {
+		t.Errorf("lineWins[0] = %+v, want %+v", got, want)
+	}
+
+	if len(got.Positions) != len(want.Positions) {
+		t.Fatalf("lineWins[0].Positions = %v, want %v", got.Positions, want.Positions)
+	}
+
+	for i := range want.Positions {
+		if got.Positions[i] != want.Positions[i] {
+			t.Errorf("lineWins[0].Positions[%d] = %v, want %v", i, got.Positions[i], want.Positions[i])
+		}
+	}
+}
+
+// TestSpinFactoryReplay проверяет воспроизведение спина по записанным случайным числам
+func TestSpinFactoryReplay(t *testing.T) {
+	factory := NewSpinFactoryFromGame(DefaultGame(), NewMockRNG([]uint64{97, 13, 42, 7, 64, 29, 55, 3, 88}))
+
+	spin, err := factory.GenerateRecorded(100)
+	if err != nil {
+		t.Fatalf("SpinFactory.GenerateRecorded() error = %v", err)
+	}
+
+	if len(spin.Draws) == 0 {
+		t.Fatal("SpinFactory.GenerateRecorded() recorded no draws")
+	}
+
+	// Фабрика без исправного RNG должна брать числа только из записи
+	replayFactory := NewSpinFactoryFromGame(DefaultGame(), nil)
+
+	replayed, err := replayFactory.Replay(100, spin.Draws)
+	if err != nil {
+		t.Fatalf("SpinFactory.Replay() error = %v", err)
+	}
+
+	if replayed.Award != spin.Award {
+		t.Errorf("replayed award = %v, want %v", replayed.Award, spin.Award)
+	}
+
+	for i := range spin.Window.Symbols {
+		for j := range spin.Window.Symbols[i] {
+			if replayed.Window.Symbols[i][j] != spin.Window.Symbols[i][j] {
+				t.Errorf("replayed symbol [%d][%d] = %v, want %v", i, j, replayed.Window.Symbols[i][j], spin.Window.Symbols[i][j])
+			}
+		}
+	}
+
+	if _, err := replayFactory.Replay(100, spin.Draws[:len(spin.Draws)-1]); err == nil {
+		t.Error("SpinFactory.Replay() with missing draws error = nil, want error")
+	}
+
+	tampered := append([]Draw{}, spin.Draws...)
+	tampered[0].Max++
+	if _, err := replayFactory.Replay(100, tampered); err == nil {
+		t.Error("SpinFactory.Replay() with tampered draws error = nil, want error")
+	}
+}
+
+// callCountingRNG считает обращения к RNG
+type callCountingRNG struct {
+	*MockRNG
+	calls int
+}
+
+func (r *callCountingRNG) Rand(max uint64) (uint64, error) {
+	r.calls++
+	return r.MockRNG.Rand(max)
+}
+
+func (r *callCountingRNG) RandSlice(maxSlice []uint64) ([]uint64, error) {
+	r.calls++
+	return r.MockRNG.RandSlice(maxSlice)
+}
+
+// TestSpinFactoryBatchedDraws проверяет, что каждый спин, включая бесплатные, запрашивает числа двумя вызовами RNG
+func TestSpinFactoryBatchedDraws(t *testing.T) {
+	for i := range DefaultGame().Reelsets {
+		// Выбираем только i-й набор барабанов, не меняя общие данные
+		game := DefaultGame()
+		game.ReelsetData = append([]ReelsetData(nil), game.ReelsetData...)
+		for j := range game.ReelsetData {
+			game.ReelsetData[j].Weight = 0
+		}
+		game.ReelsetData[i].Weight = 1
+
+		rng := &callCountingRNG{MockRNG: NewMockRNG([]uint64{0})}
+
+		spin, err := NewSpinFactoryFromGame(game, rng).Generate(100)
+		if err != nil {
+			t.Fatalf("SpinFactory.Generate() error = %v", err)
+		}
+
+		if spin.Reelset != i {
+			t.Fatalf("spin.Reelset = %d, want %d", spin.Reelset, i)
+		}
+
+		if want := 2 * (1 + len(spin.FreeSpins)); rng.calls != want {
+			t.Errorf("reelset %s: RNG calls = %d, want %d", game.ReelsetData[i].Name, rng.calls, want)
+		}
+	}
+}
+
+// TestSpinFactoryWildFeatures проверяет расширяющиеся дикие символы и случайные дикие барабаны
+func TestSpinFactoryWildFeatures(t *testing.T) {
+	reels := &Reels{
+		Reels: [][]Symbol{
+			{A, K, Q, J},
+			{Wild, A, Q, J},
+			{A, K, Q, J},
+			{Bonus, A, Q, J},
+			{A, K, Q, J},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		wilds  Wilds
+		values []uint64
+		want   []WildCell
+	}{
+		{
+			name:   "No features",
+			values: []uint64{0},
+		},
+		{
+			name:   "Expanding wild covers its reel",
+			wilds:  Wilds{Expanding: true},
+			values: []uint64{0},
+			want: []WildCell{
+				{Col: 1, Row: 1, Symbol: A, Feature: WildExpanding},
+				{Col: 1, Row: 2, Symbol: Q, Feature: WildExpanding},
+			},
+		},
+		{
+			// Набор барабанов, 5 остановок, вес числа барабанов и выбор барабана 3
+			name:   "Wild reel keeps Bonus",
+			wilds:  Wilds{WildReels: []int{0, 1}},
+			values: []uint64{0, 0, 0, 0, 0, 0, 0, 3},
+			want: []WildCell{
+				{Col: 3, Row: 1, Symbol: A, Feature: WildReel},
+				{Col: 3, Row: 2, Symbol: Q, Feature: WildReel},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			game := &Game{
+				Name:        "wilds",
+				Reelsets:    []*Reels{reels},
+				ReelsetData: []ReelsetData{{Name: "wilds", Weight: 1, Wilds: tt.wilds}},
+				Paylines:    Paylines[:1],
+				Paytable:    map[Symbol]map[int]int64{A: {3: 5, 4: 15, 5: 25}},
+			}
+
+			spin, err := NewSpinFactoryFromGame(game, NewMockRNG(tt.values)).Generate(100)
+			if err != nil {
+				t.Fatalf("SpinFactory.Generate() error = %v", err)
+			}
+
+			if len(spin.WildCells) != len(tt.want) {
+				t.Fatalf("Spin.WildCells = %+v, want %+v", spin.WildCells, tt.want)
+			}
+
+			for i, cell := range tt.want {
+				if spin.WildCells[i] != cell {
+					t.Errorf("Spin.WildCells[%d] = %+v, want %+v", i, spin.WildCells[i], cell)
+				}
+
+				if symbol := spin.Window.Symbols[cell.Col][cell.Row]; symbol != Wild {
+					t.Errorf("window [%d][%d] = %v, want WILD", cell.Col, cell.Row, symbol)
+				}
+			}
+
+			if spin.Window.Symbols[3][0] != Bonus {
+				t.Errorf("window [3][0] = %v, want BONUS", spin.Window.Symbols[3][0])
+			}
+		})
+	}
+}
+
+// TestEvaluateLineMultipliers проверяет умножение и сложение множителей диких символов на линии
+func TestEvaluateLineMultipliers(t *testing.T) {
+	paytable := map[Symbol]map[int]int64{
+		Dynamite: {3: 30, 4: 60, 5: 200},
+		Wild:     {3: 50, 4: 100, 5: 500},
+	}
+
+	tests := []struct {
+		name           string
+		symbols        []Symbol
+		multipliers    []int
+		combine        MultiplierCombine
+		wantMultiplier int
+		wantAward      int64
+	}{
+		{
+			name:           "No multipliers",
+			symbols:        []Symbol{Wild, Dynamite, Wild, Hammer, Key},
+			combine:        MultiplyMultipliers,
+			wantMultiplier: 1,
+			wantAward:      30,
+		},
+		{
+			name:           "Multiplied",
+			symbols:        []Symbol{Wild, Dynamite, Wild, Hammer, Key},
+			multipliers:    []int{2, 0, 3, 0, 0},
+			combine:        MultiplyMultipliers,
+			wantMultiplier: 6,
+			wantAward:      180,
+		},
+		{
+			name:           "Added",
+			symbols:        []Symbol{Wild, Dynamite, Wild, Hammer, Key},
+			multipliers:    []int{2, 0, 3, 0, 0},
+			combine:        AddMultipliers,
+			wantMultiplier: 5,
+			wantAward:      150,
+		},
+		{
+			// Множитель дикого символа после конца выигрыша не учитывается
+			name:           "Wild after the win",
+			symbols:        []Symbol{Dynamite, Dynamite, Dynamite, Hammer, Wild},
+			multipliers:    []int{0, 0, 0, 0, 5},
+			combine:        MultiplyMultipliers,
+			wantMultiplier: 1,
+			wantAward:      30,
+		},
+		{
+			name:           "All wilds",
+			symbols:        []Symbol{Wild, Wild, Wild, Wild, Wild},
+			multipliers:    []int{2, 2, 2, 2, 2},
+			combine:        AddMultipliers,
+			wantMultiplier: 10,
+			wantAward:      5000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, multiplier, award := evaluateLine(paytable, tt.symbols, tt.multipliers, tt.combine, 100)
+			if multiplier != tt.wantMultiplier || award != tt.wantAward {
+				t.Errorf("evaluateLine() = multiplier %d, award %d, want %d, %d",
+					multiplier, award, tt.wantMultiplier, tt.wantAward)
+			}
+		})
+	}
+}
+
+// TestSpinFactoryWildMultipliers проверяет розыгрыш множителей для диких ячеек окна
+func TestSpinFactoryWildMultipliers(t *testing.T) {
+	game := &Game{
+		Name: "multipliers",
+		Reelsets: []*Reels{{
+			Reels: [][]Symbol{
+				{Wild, A, Q},
+				{A, K, Q},
+				{Wild, A, Q},
+				{A, K, Q},
+				{A, K, Q},
+			},
+		}},
+		ReelsetData: []ReelsetData{{
+			Name:   "multipliers",
+			Weight: 1,
+			Wilds:  Wilds{Multipliers: []WildMultiplier{{Value: 2, Weight: 1}, {Value: 3, Weight: 1}}},
+		}},
+		Paylines: Paylines[1:2], // верхний ряд
+		Paytable: map[Symbol]map[int]int64{A: {3: 5, 4: 15, 5: 25}},
+	}
+
+	// Набор барабанов, 5 остановок, затем по броску множителя на ячейку по барабанам
+	values := make([]uint64, 1+5+5*WindowHeight)
+	values[1+5+2*WindowHeight] = 1 // ячейка [2][0] получает 3x
+
+	spin, err := NewSpinFactoryFromGame(game, NewMockRNG(values)).Generate(100)
+	if err != nil {
+		t.Fatalf("SpinFactory.Generate() error = %v", err)
+	}
+
+	if got := spin.Window.Multipliers[0][0]; got != 2 {
+		t.Errorf("multiplier [0][0] = %d, want 2", got)
+	}
+	if got := spin.Window.Multipliers[2][0]; got != 3 {
+		t.Errorf("multiplier [2][0] = %d, want 3", got)
+	}
+	if got := spin.Window.Multipliers[1][0]; got != 0 {
+		t.Errorf("multiplier [1][0] = %d, want 0 for a cell that is not wild", got)
+	}
+
+	if len(spin.LineWins) != 1 || spin.LineWins[0].Multiplier != 6 || spin.Award != 150 {
+		t.Errorf("Spin.LineWins = %+v, award %d, want one win with multiplier 6 and award 150", spin.LineWins, spin.Award)
+	}
+}
+
+// TestWaysEvaluator проверяет выплаты по способам на соседних барабанах
+func TestWaysEvaluator(t *testing.T) {
+	paytable := map[Symbol]map[int]int64{
+		A:    {3: 5, 4: 15, 5: 25},
+		K:    {3: 5, 4: 10, 5: 15},
+		Wild: {3: 50, 4: 100, 5: 500},
+	}
+
+	tests := []struct {
+		name        string
+		window      [][]Symbol
+		multipliers [][]int
+		combine     MultiplierCombine
+		want        []LineWin
+	}{
+		{
+			// 2 * 1 * 3 = 6 способов по три A, четвертый барабан прерывает
+			name: "Ways multiply by matching cells",
+			window: [][]Symbol{
+				{A, Q, A},
+				{J, A, Q},
+				{A, A, A},
+				{Q, J, Q},
+				{A, A, A},
+			},
+			want: []LineWin{{Symbol: A, Count: 3, Ways: 6, Multiplier: 6, Award: 30}},
+		},
+		{
+			// Wild замещает и A, и K, но сам по себе по способам не платит
+			name: "Wild substitutes for every symbol",
+			window: [][]Symbol{
+				{A, K, Q},
+				{Wild, Q, Q},
+				{K, A, J},
+				{Q, Q, Q},
+				{J, J, J},
+			},
+			want: []LineWin{
+				{Symbol: A, Count: 3, Ways: 1, Multiplier: 1, Award: 5},
+				{Symbol: K, Count: 3, Ways: 1, Multiplier: 1, Award: 5},
+			},
+		},
+		{
+			// Способы через Wild 2x и 3x: 1*2 + 1*3 = 5
+			name: "Wild multipliers per way",
+			window: [][]Symbol{
+				{A, Q, Q},
+				{Wild, Wild, Q},
+				{A, Q, Q},
+				{Q, Q, Q},
+				{J, J, J},
+			},
+			multipliers: [][]int{{0, 0, 0}, {2, 3, 0}, {0, 0, 0}, {0, 0, 0}, {0, 0, 0}},
+			combine:     MultiplyMultipliers,
+			want:        []LineWin{{Symbol: A, Count: 3, Ways: 2, Multiplier: 5, Award: 25}},
+		},
+		{
+			name: "No win",
+			window: [][]Symbol{
+				{A, K, Q},
+				{Q, Q, Q},
+				{A, K, J},
+				{A, K, Q},
+				{A, K, J},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			game := &Game{Paytable: paytable, MultiplierCombine: tt.combine, Evaluation: WaysEvaluation}
+			window := &Window{Symbols: tt.window, Multipliers: tt.multipliers}
+
+			award, lineWins := WaysEvaluator{}.Evaluate(game, window, 100)
+
+			if len(lineWins) != len(tt.want) {
+				t.Fatalf("WaysEvaluator.Evaluate() wins = %+v, want %+v", lineWins, tt.want)
+			}
+
+			var wantAward int64
+			for i, want := range tt.want {
+				got := lineWins[i]
+				if got.Payline != -1 || got.Symbol != want.Symbol || got.Count != want.Count ||
+					got.Ways != want.Ways || got.Multiplier != want.Multiplier || got.Award != want.Award {
+					t.Errorf("win %d = %+v, want %+v", i, got, want)
+				}
+				wantAward += want.Award
+			}
+
+			if award != wantAward {
+				t.Errorf("WaysEvaluator.Evaluate() award = %d, want %d", award, wantAward)
+			}
+		})
+	}
+}
+
+// fixedEvaluator платит одну и ту же сумму за любое окно
+type fixedEvaluator struct {
+	award int64
+}
+
+func (e fixedEvaluator) Evaluate(game *Game, window *Window, wager int64) (int64, []LineWin) {
+	return e.award, nil
+}
+
+// TestSpinFactoryEvaluator проверяет выбор оценщика по игре и его замену
+func TestSpinFactoryEvaluator(t *testing.T) {
+	reels := &Reels{
+		Reels: [][]Symbol{
+			{A, K, Q},
+			{Q, A, K},
+			{K, Q, A},
+			{J, J, J},
+			{J, J, J},
+		},
+	}
+
+	// Ни одна линия не собирает три A, но по способам A есть на трех барабанах
+	game := &Game{
+		Name:        "evaluator",
+		Reelsets:    []*Reels{reels},
+		ReelsetData: []ReelsetData{{Name: "evaluator", Weight: 1}},
+		Paylines:    Paylines[:3],
+		Paytable:    map[Symbol]map[int]int64{A: {3: 5, 4: 15, 5: 25}},
+	}
+
+	tests := []struct {
+		name       string
+		evaluation Evaluation
+		evaluator  Evaluator
+		want       int64
+	}{
+		{name: "Paylines by default", want: 0},
+		{name: "Ways", evaluation: WaysEvaluation, want: 5},
+		{name: "Custom evaluator", evaluator: fixedEvaluator{award: 42}, want: 42},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := *game
+			g.Evaluation = tt.evaluation
+
+			factory := NewSpinFactoryFromGame(&g, NewMockRNG([]uint64{0}))
+			if tt.evaluator != nil {
+				factory = factory.WithEvaluator(tt.evaluator)
+			}
+
+			spin, err := factory.Generate(100)
+			if err != nil {
+				t.Fatalf("SpinFactory.Generate() error = %v", err)
+			}
+
+			if spin.Award != tt.want {
+				t.Errorf("Spin.Award = %d, want %d", spin.Award, tt.want)
+			}
+		})
+	}
+}
+
+// TestClusterEvaluator проверяет выплаты за группы соседних символов
+func TestClusterEvaluator(t *testing.T) {
+	game := &Game{
+		Paytable: map[Symbol]map[int]int64{
+			A: {4: 10, 6: 30},
+			K: {4: 5},
+		},
+		Evaluation: ClusterEvaluation,
+	}
+
+	// Wild в [1][1] входит и в группу A, и в группу K
+	window := &Window{
+		Symbols: [][]Symbol{
+			{A, A, Q},
+			{A, Wild, K},
+			{J, A, K},
+			{Q, J, K},
+			{A, Q, J},
+		},
+		Multipliers: [][]int{{0, 0, 0}, {0, 2, 0}, {0, 0, 0}, {0, 0, 0}, {0, 0, 0}},
+	}
+
+	award, lineWins := ClusterEvaluator{}.Evaluate(game, window, 100)
+
+	want := []LineWin{
+		// 5 клеток платят по записи 4, множитель Wild 2x
+		{Symbol: A, Count: 5, Multiplier: 2, Award: 20},
+		{Symbol: K, Count: 4, Multiplier: 2, Award: 10},
+	}
+
+	if len(lineWins) != len(want) {
+		t.Fatalf("ClusterEvaluator.Evaluate() wins = %+v, want %+v", lineWins, want)
+	}
+
+	for i, w := range want {
+		got := lineWins[i]
+		if got.Payline != -1 || got.Symbol != w.Symbol || got.Count != w.Count || len(got.Positions) != w.Count ||
+			got.Multiplier != w.Multiplier || got.Award != w.Award {
+			t.Errorf("win %d = %+v, want %+v", i, got, w)
+		}
+	}
+
+	if award != 30 {
+		t.Errorf("ClusterEvaluator.Evaluate() award = %d, want 30", award)
+	}
+}
+
+// TestSpinFactoryCascade проверяет каскады: выигравшие клетки убираются, символы
+// падают вниз, новые приходят с ленты над остановкой, множитель растет
+func TestSpinFactoryCascade(t *testing.T) {
+	strip := []Symbol{K, A, J, J, Q}
+	game := &Game{
+		Name:        "cascade",
+		Reelsets:    []*Reels{{Reels: [][]Symbol{strip, strip, strip, strip, strip}}},
+		ReelsetData: []ReelsetData{{Name: "cascade", Weight: 1}},
+		Paylines:    Paylines[:1], // средний ряд
+		Paytable: map[Symbol]map[int]int64{
+			A: {5: 25},
+			K: {5: 15},
+		},
+		Cascade: &Cascade{Multipliers: []int{1, 2}},
+	}
+
+	spin, err := NewSpinFactoryFromGame(game, NewMockRNG([]uint64{0})).Generate(100)
+	if err != nil {
+		t.Fatalf("SpinFactory.Generate() error = %v", err)
+	}
+
+	want := []struct {
+		rows       []Symbol // верхний, средний и нижний ряд, одинаковые на всех барабанах
+		win        Symbol
+		multiplier int
+		award      int64
+	}{
+		{rows: []Symbol{K, A, J}, win: A, multiplier: 1, award: 25},
+		{rows: []Symbol{Q, K, J}, win: K, multiplier: 2, award: 30},
+		{rows: []Symbol{J, Q, J}, win: None, multiplier: 2},
+	}
+
+	if len(spin.Cascades) != len(want) {
+		t.Fatalf("Spin.Cascades has %d steps, want %d", len(spin.Cascades), len(want))
+	}
+
+	for i, w := range want {
+		step := spin.Cascades[i]
+		for col, reel := range step.Window.Symbols {
+			for row, symbol := range reel {
+				if symbol != w.rows[row] {
+					t.Errorf("step %d window [%d][%d] = %v, want %v", i, col, row, symbol, w.rows[row])
+				}
+			}
+		}
+
+		if step.Multiplier != w.multiplier || step.Award != w.award {
+			t.Errorf("step %d multiplier %d, award %d, want %d, %d", i, step.Multiplier, step.Award, w.multiplier, w.award)
+		}
+
+		if w.win != None && (len(step.LineWins) != 1 || step.LineWins[0].Symbol != w.win) {
+			t.Errorf("step %d wins = %+v, want a win of %v", i, step.LineWins, w.win)
+		}
+	}
+
+	if spin.Award != 55 || len(spin.LineWins) != 2 {
+		t.Errorf("Spin.Award = %d with %d wins, want 55 with 2", spin.Award, len(spin.LineWins))
+	}
+
+	if spin.Window.Symbols[0][1] != A {
+		t.Errorf("Spin.Window is not the landed window: %v", spin.Window.Symbols)
+	}
+
+	copied := spin.DeepCopy().(*Spin)
+	copied.Cascades[0].Window.Symbols[0][0] = Bonus
+	if spin.Cascades[0].Window.Symbols[0][0] == Bonus {
+		t.Error("Spin.DeepCopy() shares cascade windows")
+	}
+}
+
+// TestSpinFactoryWindowShape проверяет окно с разной высотой барабанов
+func TestSpinFactoryWindowShape(t *testing.T) {
+	strip := []Symbol{A, K, Q, J, A, K}
+	reels := &Reels{Reels: [][]Symbol{strip, strip, strip, strip, strip}}
+
+	tests := []struct {
+		name   string
+		shape  WindowShape
+		values []uint64
+		want   []int
+	}{
+		{
+			name:   "Default height",
+			values: []uint64{0},
+			want:   []int{3, 3, 3, 3, 3},
+		},
+		{
+			name:   "Fixed heights",
+			shape:  WindowShape{Heights: []int{3, 4, 5, 4, 3}},
+			values: []uint64{0},
+			want:   []int{3, 4, 5, 4, 3},
+		},
+		{
+			// Набор барабанов, 5 остановок, затем броски высоты каждого барабана
+			name:   "Random heights",
+			shape:  WindowShape{HeightWeights: []int{0, 1, 1, 1, 1}},
+			values: []uint64{0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 3},
+			want:   []int{1, 2, 3, 4, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			game := &Game{
+				Name:        "shape",
+				Reelsets:    []*Reels{reels},
+				ReelsetData: []ReelsetData{{Name: "shape", Weight: 1}},
+				Paytable:    map[Symbol]map[int]int64{A: {3: 5, 4: 15, 5: 25}},
+				Evaluation:  WaysEvaluation,
+				Shape:       tt.shape,
+			}
+
+			spin, err := NewSpinFactoryFromGame(game, NewMockRNG(tt.values)).Generate(100)
+			if err != nil {
+				t.Fatalf("SpinFactory.Generate() error = %v", err)
+			}
+
+			for col, reel := range spin.Window.Symbols {
+				if len(reel) != tt.want[col] {
+					t.Errorf("reel %d shows %d rows, want %d", col, len(reel), tt.want[col])
+				}
+
+				for row, symbol := range reel {
+					if symbol != strip[row] {
+						t.Errorf("window [%d][%d] = %v, want %v", col, row, symbol, strip[row])
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestApplyWildsSticky проверяет, что липкие дикие символы держатся в следующих бесплатных вращениях
+func TestApplyWildsSticky(t *testing.T) {
+	sticky := newStickyCells(3, 3)
+
+	first := &Window{Symbols: [][]Symbol{{A, K, Q}, {K, Wild, Q}, {A, K, Q}}}
+	if cells := applyWilds(first, Wilds{Sticky: true}, nil, sticky); len(cells) != 0 {
+		t.Errorf("first spin transformed %+v, want no cells", cells)
+	}
+
+	second := &Window{Symbols: [][]Symbol{{A, K, Q}, {J, J, Bonus}, {A, K, Q}}}
+	cells := applyWilds(second, Wilds{}, nil, sticky)
+
+	want := []WildCell{{Col: 1, Row: 1, Symbol: J, Feature: WildSticky}}
+	if len(cells) != 1 || cells[0] != want[0] {
+		t.Fatalf("second spin transformed %+v, want %+v", cells, want)
+	}
+
+	if second.Symbols[1][1] != Wild {
+		t.Errorf("sticky cell = %v, want WILD", second.Symbols[1][1])
+	}
+}
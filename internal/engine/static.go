@@ -1,5 +1,10 @@
 package engine
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Symbol represents a slot machine symbol
 type Symbol int
 
@@ -19,6 +24,43 @@ const (
 	Wild            // Wild (Piggy)
 )
 
+// WindowHeight is the number of visible rows on every reel of games without a window shape
+const WindowHeight = 3
+
+var symbolNames = map[Symbol]string{
+	Dynamite: "DYNAMITE",
+	Bat:      "BAT",
+	Saw:      "SAW",
+	Hammer:   "HAMMER",
+	Key:      "KEY",
+	A:        "A",
+	K:        "K",
+	Q:        "Q",
+	J:        "J",
+	Bonus:    "BONUS",
+	Wild:     "WILD",
+}
+
+// String returns the symbol name used in game definitions
+func (s Symbol) String() string {
+	if name, ok := symbolNames[s]; ok {
+		return name
+	}
+
+	return "UNKNOWN"
+}
+
+// ParseSymbol returns the symbol with the given name, ignoring case
+func ParseSymbol(name string) (Symbol, error) {
+	for symbol, symbolName := range symbolNames {
+		if strings.EqualFold(symbolName, name) {
+			return symbol, nil
+		}
+	}
+
+	return None, fmt.Errorf("unknown symbol %q", name)
+}
+
 // Multipliers for different symbols and combinations
 var symbolMultipliers = map[Symbol]map[int]int64{
 	Dynamite: {5: 200, 4: 60, 3: 30},
@@ -32,10 +74,20 @@ var symbolMultipliers = map[Symbol]map[int]int64{
 	J:        {5: 15, 4: 10, 3: 5},
 }
 
+// Free spins awarded for the number of Bonus symbols anywhere in the window
+var BonusFreeSpins = map[int]int{
+	3: 8,
+	4: 12,
+	5: 15,
+}
+
+// MaxFreeSpins caps the length of a single free spins round including retriggers
+const MaxFreeSpins = 100
+
 // Position represents a position in the slot window
 type Position struct {
-	Col int
-	Row int
+	Col int `json:"col"`
+	Row int `json:"row"`
 }
 
 // Define paylines for a 5x3 slot machine based on docs/paylines.csv
@@ -249,21 +301,53 @@ type Reels struct {
 
 // Window represents the visible symbols in the slot machine
 type Window struct {
-	Symbols [][]Symbol
+	Symbols     [][]Symbol
+	Multipliers [][]int // multiplier of every wild cell, 0 for other cells; nil without wild multipliers
 }
 
 // Spin represents a single spin result
 type Spin struct {
-	Window       *Window
-	Stops        []int
-	Wager        int64
-	Award        int64
-	BaseAwardVal int64
+	Window        *Window
+	Reelset       int // index of the selected reelset in the game
+	Stops         []int
+	WildCells     []WildCell // cells turned Wild by the wild features
+	Wager         int64
+	Award         int64
+	BaseAwardVal  int64
+	BonusAwardVal int64
+	LineWins      []LineWin      // wins of every cascade step with cascades
+	Cascades      []*CascadeStep // windows evaluated one after another, nil without cascades
+	FreeSpins     []*FreeSpin
+	Draws         []Draw // random draws consumed, set by GenerateRecorded and Replay
+}
+
+// FreeSpin represents a single free spin played inside a bonus round
+type FreeSpin struct {
+	Window    *Window
+	Reelset   int
+	Stops     []int
+	WildCells []WildCell
+	Award     int64
+	LineWins  []LineWin
+	Cascades  []*CascadeStep
+}
+
+// LineWin represents a win on a single payline
+type LineWin struct {
+	Payline    int        // index in Paylines, -1 for ways and cluster wins
+	Symbol     Symbol     // paying symbol, Wild for all-wild lines
+	Count      int        // consecutive matches from the leftmost reel, the size of a cluster
+	Positions  []Position // window positions forming the win
+	Ways       int        // number of ways of a ways win, 0 for payline wins
+	Multiplier int        // combined multiplier of the wilds in the win, 1 without; summed over the ways of a ways win
+	Award      int64
 }
 
-// RNG interface for random number generation
+// RNG interface for random number generation. RandSlice draws a number
+// below every element of maxSlice in a single request.
 type RNG interface {
 	Rand(max uint64) (uint64, error)
+	RandSlice(maxSlice []uint64) ([]uint64, error)
 }
 
 // Gamble represents a gamble feature
@@ -278,43 +362,43 @@ type RestoringIndexes interface {
 }
 
 type ReelsetData struct {
-	Name             string
-	Weight           int
-	Probability      float64
-	WildsProbability float64
-	RTP              float64
+	Name        string
+	Weight      int
+	Probability float64
+	Wilds       Wilds
+	RTP         float64
 }
 
 var ReelsetWeights = []int{85, 7, 2, 6}
 
 var AllReelsetData = []ReelsetData{
 	{
-		Name:             "Main Math1",
-		Weight:           85,
-		Probability:      0.85,
-		WildsProbability: 0.0,
-		RTP:              0.1155632751,
+		Name:        "Main Math1",
+		Weight:      85,
+		Probability: 0.85,
+		RTP:         0.1155632751,
 	},
 	{
-		Name:             "Main Math2",
-		Weight:           7,
-		Probability:      0.07,
-		WildsProbability: 0.0,
-		RTP:              0.07477371305,
+		Name:        "Main Math2",
+		Weight:      7,
+		Probability: 0.07,
+		RTP:         0.07477371305,
 	},
 	{
-		Name:             "Main Math3",
-		Weight:           2,
-		Probability:      0.02,
-		WildsProbability: 1.0,
-		RTP:              0.1690189141,
+		Name:        "Main Math3",
+		Weight:      2,
+		Probability: 0.02,
+		// Every reel turns wild, only Bonus symbols stay
+		Wilds: Wilds{WildReels: []int{0, 0, 0, 0, 0, 1}},
+		RTP:   0.1690189141,
 	},
 	{
-		Name:             "Main Math4",
-		Weight:           6,
-		Probability:      0.06,
-		WildsProbability: 1.0,
-		RTP:              0.09961106012,
+		Name:        "Main Math4",
+		Weight:      6,
+		Probability: 0.06,
+		// Every reel turns wild, only Bonus symbols stay
+		Wilds: Wilds{WildReels: []int{0, 0, 0, 0, 0, 1}},
+		RTP:   0.09961106012,
 	},
 }
 
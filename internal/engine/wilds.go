@@ -0,0 +1,270 @@
+package engine
+
+import "slices"
+
+// WildFeature is the wild feature that transformed a cell
+type WildFeature string
+
+const (
+	WildExpanding WildFeature = "expanding" // a wild on the reel expanded over it
+	WildReel      WildFeature = "wild_reel" // the reel was picked as a random piggy wild reel
+	WildSticky    WildFeature = "sticky"    // a wild of an earlier free spin held the cell
+)
+
+// Wilds configures the wild features of a reelset. Wild symbols placed on the
+// reel strips substitute where they land, the features turn more cells wild.
+// Bonus symbols are never transformed, so wilds do not change the free spins trigger.
+type Wilds struct {
+	// Expanding wilds cover their whole reel
+	Expanding bool `json:"expanding,omitempty" yaml:"expanding,omitempty"`
+	// Sticky wilds of a free spin hold their cells for the rest of the round
+	Sticky bool `json:"sticky,omitempty" yaml:"sticky,omitempty"`
+	// WildReels are the weights of the number of random reels turned wild,
+	// indexed by the number of reels
+	WildReels []int `json:"wild_reels,omitempty" yaml:"wild_reels,omitempty"`
+	// Multipliers are drawn by weight for every wild cell of the window
+	Multipliers []WildMultiplier `json:"multipliers,omitempty" yaml:"multipliers,omitempty"`
+}
+
+// WildMultiplier is a multiplier a wild can carry and its weight
+type WildMultiplier struct {
+	Value  int `json:"value" yaml:"value"`
+	Weight int `json:"weight" yaml:"weight"`
+}
+
+// MultiplierCombine is how the multipliers of the wilds on a winning line combine
+type MultiplierCombine string
+
+const (
+	MultiplyMultipliers MultiplierCombine = "multiply" // 2x and 3x wilds pay 6x, the default
+	AddMultipliers      MultiplierCombine = "add"      // 2x and 3x wilds pay 5x
+)
+
+// start is the combined multiplier of a line without wilds yet
+func (c MultiplierCombine) start() int {
+	if c == AddMultipliers {
+		return 0
+	}
+
+	return 1
+}
+
+// add combines the multiplier of a wild into combined, 0 is a wild without multiplier
+func (c MultiplierCombine) add(combined, multiplier int) int {
+	switch {
+	case multiplier == 0:
+		return combined
+	case c == AddMultipliers:
+		return combined + multiplier
+	default:
+		return combined * multiplier
+	}
+}
+
+// total is the multiplier of the line, 1 when no wild carried one
+func (c MultiplierCombine) total(combined int) int {
+	return max(combined, 1)
+}
+
+// WildCell is a cell of the window transformed into Wild by a wild feature
+type WildCell struct {
+	Col     int         `json:"col"`
+	Row     int         `json:"row"`
+	Symbol  Symbol      `json:"symbol"` // symbol that landed in the cell
+	Feature WildFeature `json:"feature"`
+}
+
+// wildReelDraws returns the ranges drawn for the random wild reels: the weight
+// roll of the number of reels, then one pick for every reel that can be chosen
+func (w Wilds) wildReelDraws(width int) []uint64 {
+	if len(w.WildReels) == 0 {
+		return nil
+	}
+
+	total := 0
+	for _, weight := range w.WildReels {
+		total += weight
+	}
+
+	draws := []uint64{uint64(total)}
+	for picked := 0; picked < len(w.WildReels)-1; picked++ {
+		draws = append(draws, uint64(width-picked))
+	}
+
+	return draws
+}
+
+// pickWildReels returns the reels turned wild for the draws of wildReelDraws.
+// The reels are picked by a partial Fisher-Yates shuffle, picks beyond the
+// drawn number of reels are unused.
+func (w Wilds) pickWildReels(width int, draws []uint64) []int {
+	if len(draws) == 0 {
+		return nil
+	}
+
+	count, cumulative := 0, uint64(0)
+	for i, weight := range w.WildReels {
+		cumulative += uint64(weight)
+		if draws[0] < cumulative {
+			count = i
+			break
+		}
+	}
+
+	reels := make([]int, width)
+	for i := range reels {
+		reels[i] = i
+	}
+
+	for picked := 0; picked < count; picked++ {
+		j := picked + int(draws[1+picked])
+		reels[picked], reels[j] = reels[j], reels[picked]
+	}
+
+	return reels[:count]
+}
+
+// multiplierDraws returns the ranges drawn for the multipliers of cells that may be wild
+func (w Wilds) multiplierDraws(cells int) []uint64 {
+	if len(w.Multipliers) == 0 {
+		return nil
+	}
+
+	draws := make([]uint64, cells)
+	for i := range draws {
+		draws[i] = uint64(w.multiplierWeight())
+	}
+
+	return draws
+}
+
+func (w Wilds) multiplierWeight() int {
+	total := 0
+	for _, multiplier := range w.Multipliers {
+		total += multiplier.Weight
+	}
+
+	return total
+}
+
+// pickMultiplier returns the multiplier for a roll below multiplierWeight
+func (w Wilds) pickMultiplier(roll uint64) int {
+	cumulative := uint64(0)
+	for _, multiplier := range w.Multipliers {
+		cumulative += uint64(multiplier.Weight)
+		if roll < cumulative {
+			return multiplier.Value
+		}
+	}
+
+	return 1
+}
+
+// assignMultipliers sets the multipliers of the wild cells of the window from
+// the draws of multiplierDraws, rows draws per reel column by column
+func (w Wilds) assignMultipliers(window *Window, rows int, draws []uint64) {
+	if len(w.Multipliers) == 0 {
+		return
+	}
+
+	window.Multipliers = make([][]int, len(window.Symbols))
+	for col, reel := range window.Symbols {
+		window.Multipliers[col] = make([]int, len(reel))
+		for row, symbol := range reel {
+			if symbol == Wild {
+				window.Multipliers[col][row] = w.pickMultiplier(draws[col*rows+row])
+			}
+		}
+	}
+}
+
+// multiplier returns the multiplier of the cell, 0 when it carries none
+func (w *Window) multiplier(col, row int) int {
+	if col >= len(w.Multipliers) || row >= len(w.Multipliers[col]) {
+		return 0
+	}
+
+	return w.Multipliers[col][row]
+}
+
+// applyWilds transforms the window with the wild features of the reelset and
+// the sticky cells of the round, and returns the transformed cells. Wilds of
+// a reelset with sticky wilds are added to sticky.
+func applyWilds(window *Window, wilds Wilds, wildReels []int, sticky [][]bool) []WildCell {
+	var cells []WildCell
+
+	transform := func(col, row int, feature WildFeature) {
+		symbol := window.Symbols[col][row]
+		if symbol == Wild || symbol == Bonus {
+			return
+		}
+
+		window.Symbols[col][row] = Wild
+		cells = append(cells, WildCell{Col: col, Row: row, Symbol: symbol, Feature: feature})
+	}
+
+	for _, col := range wildReels {
+		for row := range window.Symbols[col] {
+			transform(col, row, WildReel)
+		}
+	}
+
+	for col, reel := range window.Symbols {
+		if wilds.Expanding && slices.Contains(reel, Wild) {
+			for row := range reel {
+				transform(col, row, WildExpanding)
+			}
+		}
+	}
+
+	for col := range sticky {
+		for row, held := range sticky[col] {
+			if held && col < len(window.Symbols) && row < len(window.Symbols[col]) {
+				transform(col, row, WildSticky)
+			}
+		}
+	}
+
+	if wilds.Sticky && sticky != nil {
+		for col, reel := range window.Symbols {
+			for row, symbol := range reel {
+				if symbol == Wild && col < len(sticky) && row < len(sticky[col]) {
+					sticky[col][row] = true
+				}
+			}
+		}
+	}
+
+	return cells
+}
+
+// newStickyCells returns the sticky wild cells of a free spins round on the window
+func newStickyCells(width, height int) [][]bool {
+	cells := make([][]bool, width)
+	for i := range cells {
+		cells[i] = make([]bool, height)
+	}
+
+	return cells
+}
+
+func copyMultipliers(multipliers [][]int) [][]int {
+	if multipliers == nil {
+		return nil
+	}
+
+	copied := make([][]int, len(multipliers))
+	for i, col := range multipliers {
+		copied[i] = append([]int(nil), col...)
+	}
+
+	return copied
+}
+
+func copyWildCells(cells []WildCell) []WildCell {
+	if cells == nil {
+		return nil
+	}
+
+	return append([]WildCell(nil), cells...)
+}
@@ -0,0 +1,105 @@
+package engine
+
+// WindowShape is the number of visible rows of every reel. An empty shape
+// shows WindowHeight rows on every reel.
+type WindowShape struct {
+	// Heights are the fixed rows of every reel, e.g. 3-4-5-4-3
+	Heights []int `json:"heights,omitempty" yaml:"heights,omitempty"`
+	// HeightWeights draw the rows of every reel on every spin like Megaways,
+	// weights are indexed by the number of rows
+	HeightWeights []int `json:"height_weights,omitempty" yaml:"height_weights,omitempty"`
+}
+
+// maxHeight returns the most rows the reel can show
+func (s WindowShape) maxHeight(col int) int {
+	switch {
+	case len(s.HeightWeights) > 0:
+		return len(s.HeightWeights) - 1
+	case col < len(s.Heights):
+		return s.Heights[col]
+	default:
+		return WindowHeight
+	}
+}
+
+// minHeight returns the fewest rows the reel can show
+func (s WindowShape) minHeight(col int) int {
+	for height, weight := range s.HeightWeights {
+		if weight > 0 {
+			return height
+		}
+	}
+
+	return s.maxHeight(col)
+}
+
+// rows returns the most rows any of the reels can show
+func (s WindowShape) rows(width int) int {
+	rows := 0
+	for col := 0; col < width; col++ {
+		rows = max(rows, s.maxHeight(col))
+	}
+
+	return rows
+}
+
+// heightDraws returns the ranges drawn for the rows of every reel, none for fixed heights
+func (s WindowShape) heightDraws(width int) []uint64 {
+	if len(s.HeightWeights) == 0 {
+		return nil
+	}
+
+	total := 0
+	for _, weight := range s.HeightWeights {
+		total += weight
+	}
+
+	draws := make([]uint64, width)
+	for i := range draws {
+		draws[i] = uint64(total)
+	}
+
+	return draws
+}
+
+// heights returns the rows of every reel for the draws of heightDraws
+func (s WindowShape) heights(width int, draws []uint64) []int {
+	heights := make([]int, width)
+	for col := range heights {
+		heights[col] = s.maxHeight(col)
+	}
+
+	for col, roll := range draws {
+		cumulative := uint64(0)
+		for height, weight := range s.HeightWeights {
+			cumulative += uint64(weight)
+			if roll < cumulative {
+				heights[col] = height
+				break
+			}
+		}
+	}
+
+	return heights
+}
+
+// heightProbabilities returns the probability of every number of rows of the reel
+func (s WindowShape) heightProbabilities(col int) map[int]float64 {
+	if len(s.HeightWeights) == 0 {
+		return map[int]float64{s.maxHeight(col): 1}
+	}
+
+	total := 0
+	for _, weight := range s.HeightWeights {
+		total += weight
+	}
+
+	probabilities := make(map[int]float64)
+	for height, weight := range s.HeightWeights {
+		if weight > 0 {
+			probabilities[height] = float64(weight) / float64(total)
+		}
+	}
+
+	return probabilities
+}
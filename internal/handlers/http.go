@@ -1,30 +1,44 @@
 package handlers
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"piggy-bank/internal/app"
 	"piggy-bank/internal/engine"
+	"piggy-bank/internal/history"
 	"piggy-bank/internal/rng"
+	"piggy-bank/internal/simulator"
+	"piggy-bank/internal/wallet"
 )
 
 type Handler struct {
 	spinFactory *engine.SpinFactory
 	rngService  *rng.Service
+	wallet      *wallet.Wallet
+	adminToken  string
+	gameHash    string
+	history     history.Store
+	simulations *simulator.Jobs
 }
 
 func NewHandler(app *app.App) *Handler {
 	rngService := app.GetRngService()
 
-	reels := engine.RealisticReels()
-
-	spinFactory := engine.NewSpinFactory(reels, rngService.GetClient())
+	spinFactory := engine.NewSpinFactoryFromGame(app.GetGame(), rngService.GetClient())
 
 	return &Handler{
 		spinFactory: spinFactory,
 		rngService:  rngService,
+		wallet:      app.GetWallet(),
+		adminToken:  app.AdminToken,
+		gameHash:    app.GetGame().Hash(),
+		history:     app.GetHistory(),
+		simulations: simulator.NewJobs(app.GetGame(), rngService),
 	}
 }
 
@@ -32,41 +46,52 @@ type SpinResponse struct {
 	Success bool   `json:"success"`
 	Error   string `json:"error,omitempty"`
 	Result  struct {
-		Wager       int64             `json:"wager"`
-		Award       int64             `json:"award"`
-		Stops       []int             `json:"stops"`
-		Symbols     [][]engine.Symbol `json:"symbols"`
-		SymbolsText [][]string        `json:"symbols_text"`
+		ID          string             `json:"id"`
+		Balance     *int64             `json:"balance,omitempty"`
+		Wager       int64              `json:"wager"`
+		Award       int64              `json:"award"`
+		BaseAward   int64              `json:"base_award"`
+		BonusAward  int64              `json:"bonus_award"`
+		Stops       []int              `json:"stops"`
+		Symbols     [][]engine.Symbol  `json:"symbols"`
+		SymbolsText [][]string         `json:"symbols_text"`
+		Lines       []LineWinResponse  `json:"lines"`
+		Wilds       []engine.WildCell  `json:"wilds,omitempty"`
+		Multipliers [][]int            `json:"multipliers,omitempty"`
+		Cascades    []CascadeResponse  `json:"cascades,omitempty"`
+		FreeSpins   []FreeSpinResponse `json:"free_spins,omitempty"`
 	} `json:"result,omitempty"`
 }
 
-func symbolToString(symbol engine.Symbol) string {
-	switch symbol {
-	case engine.Dynamite:
-		return "DYNAMITE"
-	case engine.Bat:
-		return "BAT"
-	case engine.Saw:
-		return "SAW"
-	case engine.Hammer:
-		return "HAMMER"
-	case engine.Key:
-		return "KEY"
-	case engine.A:
-		return "A"
-	case engine.K:
-		return "K"
-	case engine.Q:
-		return "Q"
-	case engine.J:
-		return "J"
-	case engine.Bonus:
-		return "BONUS"
-	case engine.Wild:
-		return "WILD"
-	default:
-		return "UNKNOWN"
-	}
+type FreeSpinResponse struct {
+	Award       int64             `json:"award"`
+	Stops       []int             `json:"stops"`
+	Symbols     [][]engine.Symbol `json:"symbols"`
+	SymbolsText [][]string        `json:"symbols_text"`
+	Lines       []LineWinResponse `json:"lines"`
+	Wilds       []engine.WildCell `json:"wilds,omitempty"`
+	Multipliers [][]int           `json:"multipliers,omitempty"`
+	Cascades    []CascadeResponse `json:"cascades,omitempty"`
+}
+
+type CascadeResponse struct {
+	Symbols     [][]engine.Symbol `json:"symbols"`
+	SymbolsText [][]string        `json:"symbols_text"`
+	Multipliers [][]int           `json:"multipliers,omitempty"`
+	Lines       []LineWinResponse `json:"lines"`
+	Multiplier  int               `json:"multiplier"`
+	Award       int64             `json:"award"`
+}
+
+type LineWinResponse struct {
+	Payline    int               `json:"payline"`
+	Symbol     engine.Symbol     `json:"symbol"`
+	SymbolText string            `json:"symbol_text"`
+	Count      int               `json:"count"`
+	Positions  []engine.Position `json:"positions"`
+	Ways       int               `json:"ways,omitempty"`
+	Multiplier int               `json:"multiplier"`
+	Award      int64             `json:"award"`
 }
 
 func (h *Handler) HandleSpin(w http.ResponseWriter, r *http.Request) {
@@ -92,36 +117,365 @@ func (h *Handler) HandleSpin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	spin, err := h.spinFactory.Generate(wager)
+	var (
+		spin   *engine.Spin
+		id     = history.NewID()
+		player = r.URL.Query().Get("player")
+	)
+
+	play := func() (int64, error) {
+		spin, err = h.spinFactory.GenerateRecorded(wager)
+		if err != nil {
+			return 0, err
+		}
+
+		return spin.Award, nil
+	}
+
+	if h.wallet == nil {
+		_, err = play()
+	} else {
+		if player == "" {
+			resp.Success = false
+			resp.Error = "missing player parameter"
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+
+		var balance int64
+		balance, err = h.wallet.Play(player, wager, id, play)
+		resp.Result.Balance = &balance
+	}
+
 	if err != nil {
 		resp.Success = false
 		resp.Error = err.Error()
+		resp.Result.Balance = nil
 		json.NewEncoder(w).Encode(resp)
 		return
 	}
 
+	// Only spins whose wager and award reached the ledger are recorded. The
+	// spin stands when the record is lost, the player gets the settled result.
+	if err := h.history.Save(history.NewRecord(id, h.spinFactory.Game().Name, h.gameHash, player, spin)); err != nil {
+		log.Printf("ALERT: spin %s of player %q was settled but not recorded: %v", id, player, err)
+	}
+
+	resp.Result.ID = id
 	resp.Result.Wager = spin.Wager
 	resp.Result.Award = spin.Award
+	resp.Result.BaseAward = spin.BaseAward()
+	resp.Result.BonusAward = spin.BonusAward()
 	resp.Result.Stops = spin.Stops
+	resp.Result.Symbols, resp.Result.SymbolsText = windowToResponse(spin.Window)
+	resp.Result.Lines = lineWinsToResponse(spin.LineWins)
+	resp.Result.Wilds = spin.WildCells
+	resp.Result.Multipliers = spin.Window.Multipliers
+	resp.Result.Cascades = cascadesToResponse(spin.Cascades)
 
-	resp.Result.Symbols = make([][]engine.Symbol, len(spin.Window.Symbols))
-	resp.Result.SymbolsText = make([][]string, len(spin.Window.Symbols))
+	for _, freeSpin := range spin.FreeSpins {
+		symbols, symbolsText := windowToResponse(freeSpin.Window)
+		resp.Result.FreeSpins = append(resp.Result.FreeSpins, FreeSpinResponse{
+			Award:       freeSpin.Award,
+			Stops:       freeSpin.Stops,
+			Symbols:     symbols,
+			SymbolsText: symbolsText,
+			Lines:       lineWinsToResponse(freeSpin.LineWins),
+			Wilds:       freeSpin.WildCells,
+			Multipliers: freeSpin.Window.Multipliers,
+			Cascades:    cascadesToResponse(freeSpin.Cascades),
+		})
+	}
 
-	for i, col := range spin.Window.Symbols {
-		resp.Result.Symbols[i] = make([]engine.Symbol, len(col))
-		resp.Result.SymbolsText[i] = make([]string, len(col))
+	json.NewEncoder(w).Encode(resp)
+}
+
+func lineWinsToResponse(lineWins []engine.LineWin) []LineWinResponse {
+	lines := make([]LineWinResponse, 0, len(lineWins))
+	for _, lineWin := range lineWins {
+		lines = append(lines, LineWinResponse{
+			Payline:    lineWin.Payline,
+			Symbol:     lineWin.Symbol,
+			SymbolText: lineWin.Symbol.String(),
+			Count:      lineWin.Count,
+			Positions:  lineWin.Positions,
+			Ways:       lineWin.Ways,
+			Multiplier: lineWin.Multiplier,
+			Award:      lineWin.Award,
+		})
+	}
+
+	return lines
+}
+
+func cascadesToResponse(steps []*engine.CascadeStep) []CascadeResponse {
+	var cascades []CascadeResponse
+	for _, step := range steps {
+		symbols, symbolsText := windowToResponse(step.Window)
+		cascades = append(cascades, CascadeResponse{
+			Symbols:     symbols,
+			SymbolsText: symbolsText,
+			Multipliers: step.Window.Multipliers,
+			Lines:       lineWinsToResponse(step.LineWins),
+			Multiplier:  step.Multiplier,
+			Award:       step.Award,
+		})
+	}
+
+	return cascades
+}
+
+func windowToResponse(window *engine.Window) ([][]engine.Symbol, [][]string) {
+	symbols := make([][]engine.Symbol, len(window.Symbols))
+	symbolsText := make([][]string, len(window.Symbols))
+
+	for i, col := range window.Symbols {
+		symbols[i] = make([]engine.Symbol, len(col))
+		symbolsText[i] = make([]string, len(col))
 
 		for j, symbol := range col {
-			resp.Result.Symbols[i][j] = symbol
-			resp.Result.SymbolsText[i][j] = symbolToString(symbol)
+			symbols[i][j] = symbol
+			symbolsText[i][j] = symbol.String()
 		}
 	}
 
+	return symbols, symbolsText
+}
+
+type ReplayResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	Result  struct {
+		ID              string            `json:"id"`
+		Match           bool              `json:"match"`
+		Wager           int64             `json:"wager"`
+		RecordedAward   int64             `json:"recorded_award"`
+		Award           int64             `json:"award"`
+		RecordedSymbols [][]engine.Symbol `json:"recorded_symbols"`
+		Symbols         [][]engine.Symbol `json:"symbols"`
+		SymbolsText     [][]string        `json:"symbols_text"`
+		Draws           []engine.Draw     `json:"draws"`
+	} `json:"result,omitempty"`
+}
+
+// HandleReplay re-derives a recorded spin from its random draws and
+// reports whether the whole spin, free spins and cascades included,
+// matches the recorded one
+func (h *Handler) HandleReplay(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	resp := ReplayResponse{Success: true}
+
+	record, err := h.history.Get(r.PathValue("id"))
+	if err != nil {
+		resp.Success = false
+		resp.Error = err.Error()
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	if err := record.CheckReplay(h.spinFactory.Game().Name, h.gameHash); err != nil {
+		resp.Success = false
+		resp.Error = err.Error()
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	spin, err := h.spinFactory.Replay(record.Wager, record.Draws)
+	if err != nil {
+		resp.Success = false
+		resp.Error = err.Error()
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	resp.Result.ID = record.ID
+	resp.Result.Wager = record.Wager
+	resp.Result.RecordedAward = record.Award
+	resp.Result.Award = spin.Award
+	resp.Result.RecordedSymbols = record.Window
+	resp.Result.Symbols, resp.Result.SymbolsText = windowToResponse(spin.Window)
+	resp.Result.Draws = record.Draws
+	resp.Result.Match = record.Matches(spin)
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+type WalletResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	Result  struct {
+		Player       string               `json:"player"`
+		Balance      int64                `json:"balance"`
+		Transactions []wallet.Transaction `json:"transactions,omitempty"`
+	} `json:"result,omitempty"`
+}
+
+func (h *Handler) HandleBalance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	resp := WalletResponse{Success: true}
+	resp.Result.Player = r.PathValue("player")
+
+	balance, err := h.wallet.Balance(resp.Result.Player)
+	if err == nil {
+		resp.Result.Transactions, err = h.wallet.Transactions(resp.Result.Player)
+	}
+
+	if err != nil {
+		resp.Success = false
+		resp.Error = err.Error()
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	resp.Result.Balance = balance
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleDeposit credits a player wallet
+func (h *Handler) HandleDeposit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	resp := WalletResponse{Success: true}
+	resp.Result.Player = r.PathValue("player")
+
+	amount, err := strconv.ParseInt(r.URL.Query().Get("amount"), 10, 64)
+	if err != nil {
+		resp.Success = false
+		resp.Error = "invalid amount value"
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	balance, err := h.wallet.Deposit(resp.Result.Player, amount)
+	if err != nil {
+		resp.Success = false
+		resp.Error = err.Error()
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	resp.Result.Balance = balance
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// requireAdmin serves next only to requests carrying the admin token as a
+// bearer credential, all requests are refused while no token is set
+func (h *Handler) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || h.adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "unauthorized"})
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+type ErrorResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+type SimulationResponse struct {
+	Success bool               `json:"success"`
+	Error   string             `json:"error,omitempty"`
+	Result  *simulator.JobView `json:"result,omitempty"`
+}
+
+// HandleCreateSimulation starts a simulation with the spins, wager and workers of the JSON body
+func (h *Handler) HandleCreateSimulation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	resp := SimulationResponse{Success: true}
+
+	var req simulator.JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.Success = false
+		resp.Error = "invalid simulation request: " + err.Error()
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	job, err := h.simulations.Start(req)
+	if err != nil {
+		resp.Success = false
+		resp.Error = err.Error()
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	resp.Result = job.View()
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleGetSimulation returns the progress of a simulation and its report once done
+func (h *Handler) HandleGetSimulation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	resp := SimulationResponse{Success: true}
+
+	job, err := h.simulations.Get(r.PathValue("id"))
+	if err != nil {
+		resp.Success = false
+		resp.Error = err.Error()
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	resp.Result = job.View()
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleCancelSimulation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	resp := SimulationResponse{Success: true}
+
+	job, err := h.simulations.Cancel(r.PathValue("id"))
+	if err != nil {
+		resp.Success = false
+		resp.Error = err.Error()
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	resp.Result = job.View()
+
 	json.NewEncoder(w).Encode(resp)
 }
 
 func (h *Handler) SetupRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("GET /spin", h.HandleSpin)
+	if h.wallet == nil {
+		mux.HandleFunc("GET /spin", h.HandleSpin)
+	}
+	mux.HandleFunc("GET /spin/{id}/replay", h.HandleReplay)
+
+	// Simulation jobs draw from the live RNG, so only operators may run them
+	mux.HandleFunc("POST /simulations", h.requireAdmin(h.HandleCreateSimulation))
+	mux.HandleFunc("GET /simulations/{id}", h.requireAdmin(h.HandleGetSimulation))
+	mux.HandleFunc("DELETE /simulations/{id}", h.requireAdmin(h.HandleCancelSimulation))
+
+	// With wallets the lobby authenticates the players and calls on their
+	// behalf, so spins and ledgers are only served to the admin token
+	if h.wallet != nil {
+		mux.HandleFunc("GET /spin", h.requireAdmin(h.HandleSpin))
+		mux.HandleFunc("GET /wallet/{player}", h.requireAdmin(h.HandleBalance))
+		mux.HandleFunc("POST /wallet/{player}/deposit", h.requireAdmin(h.HandleDeposit))
+	}
 }
 
 func SetupServer(address string, handler *Handler) *http.Server {
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"piggy-bank/internal/app"
+	"piggy-bank/internal/engine"
+	"piggy-bank/internal/history"
+	"piggy-bank/internal/rng"
+	"piggy-bank/internal/wallet"
+)
+
+func newTestServer(t *testing.T, adminToken string) *httptest.Server {
+	t.Helper()
+
+	return serve(t, &app.App{
+		RngService: rng.NewSeededService(42),
+		Game:       engine.DefaultGame(),
+		History:    history.NewMemoryStore(history.DefaultCapacity),
+		AdminToken: adminToken,
+	})
+}
+
+func serve(t *testing.T, application *app.App) *httptest.Server {
+	t.Helper()
+
+	handler := NewHandler(application)
+
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func request(t *testing.T, server *httptest.Server, method, path, token, body string) int {
+	t.Helper()
+
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("http.NewRequest() error = %v", err)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s error = %v", method, path, err)
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func TestSimulationsRequireAdmin(t *testing.T) {
+	const body = `{"spins": 1000, "wager": 100, "workers": 1}`
+
+	server := newTestServer(t, "secret")
+
+	for _, token := range []string{"", "wrong"} {
+		for _, route := range []struct{ method, path string }{
+			{http.MethodPost, "/simulations"},
+			{http.MethodGet, "/simulations/some-id"},
+			{http.MethodDelete, "/simulations/some-id"},
+		} {
+			if code := request(t, server, route.method, route.path, token, body); code != http.StatusUnauthorized {
+				t.Errorf("%s %s with token %q = %d, want 401", route.method, route.path, token, code)
+			}
+		}
+	}
+
+	if code := request(t, server, http.MethodPost, "/simulations", "secret", body); code != http.StatusOK {
+		t.Errorf("POST /simulations with the admin token = %d, want 200", code)
+	}
+
+	// Without a configured token every request is refused
+	if code := request(t, newTestServer(t, ""), http.MethodPost, "/simulations", "", body); code != http.StatusUnauthorized {
+		t.Errorf("POST /simulations without an admin token = %d, want 401", code)
+	}
+}
+
+// failingStore loses every record
+type failingStore struct{}
+
+func (failingStore) Save(*history.Record) error          { return errors.New("disk full") }
+func (failingStore) Get(string) (*history.Record, error) { return nil, history.ErrNotFound }
+
+func TestWalletSpin(t *testing.T) {
+	playerWallet := wallet.NewWallet(wallet.NewMemoryStorage())
+	if _, err := playerWallet.Deposit("player", 1000); err != nil {
+		t.Fatalf("Deposit() error = %v", err)
+	}
+
+	server := serve(t, &app.App{
+		RngService: rng.NewSeededService(42),
+		Game:       engine.DefaultGame(),
+		Wallet:     playerWallet,
+		History:    failingStore{},
+		AdminToken: "secret",
+	})
+
+	for _, path := range []string{"/spin?wager=100&player=player", "/wallet/player"} {
+		if code := request(t, server, http.MethodGet, path, "", ""); code != http.StatusUnauthorized {
+			t.Errorf("GET %s without the admin token = %d, want 401", path, code)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/spin?wager=100&player=player", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /spin error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	var spin SpinResponse
+	if err := json.NewDecoder(resp.Body).Decode(&spin); err != nil {
+		t.Fatalf("decoding the spin response: %v", err)
+	}
+
+	// The ledger committed the spin, losing its history record does not undo it
+	if !spin.Success || spin.Result.Balance == nil {
+		t.Fatalf("spin response = %+v, want the settled spin", spin)
+	}
+
+	balance, _ := playerWallet.Balance("player")
+	if want := 1000 - spin.Result.Wager + spin.Result.Award; *spin.Result.Balance != want || balance != want {
+		t.Errorf("balance = %d, response %d, want %d", balance, *spin.Result.Balance, want)
+	}
+}
@@ -0,0 +1,224 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"piggy-bank/internal/engine"
+)
+
+var ErrNotFound = errors.New("spin not found")
+
+// Record is everything needed to audit a played spin. The draws replay only
+// on the game hash and draw layout version they were recorded with. Outcome
+// is the whole played spin without its draws, free spins and cascades
+// included.
+type Record struct {
+	ID            string            `json:"id"`
+	Game          string            `json:"game"`
+	GameHash      string            `json:"game_hash"`
+	LayoutVersion int               `json:"layout_version"`
+	Player        string            `json:"player,omitempty"`
+	Wager         int64             `json:"wager"`
+	Award         int64             `json:"award"`
+	Window        [][]engine.Symbol `json:"window"`
+	Outcome       json.RawMessage   `json:"outcome"`
+	Draws         []engine.Draw     `json:"draws"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+// NewRecord builds the record of a spin generated with SpinFactory.GenerateRecorded
+// on the game with the given name and Game.Hash
+func NewRecord(id, game, gameHash, player string, spin *engine.Spin) *Record {
+	window := make([][]engine.Symbol, len(spin.Window.Symbols))
+	for i, col := range spin.Window.Symbols {
+		window[i] = make([]engine.Symbol, len(col))
+		copy(window[i], col)
+	}
+
+	return &Record{
+		ID:            id,
+		Game:          game,
+		GameHash:      gameHash,
+		LayoutVersion: engine.DrawLayoutVersion,
+		Player:        player,
+		Wager:         spin.Wager,
+		Award:         spin.Award,
+		Window:        window,
+		Outcome:       outcome(spin),
+		Draws:         spin.Draws,
+		CreatedAt:     time.Now(),
+	}
+}
+
+// CheckReplay returns an error unless the draws of the record replay on the
+// game with the given name and hash with the current draw layout
+func (r *Record) CheckReplay(game, gameHash string) error {
+	switch {
+	case r.Game != game:
+		return fmt.Errorf("spin was played on game %s, server runs %s", r.Game, game)
+	case r.GameHash != gameHash:
+		return fmt.Errorf("spin was played on another version of game %s", game)
+	case r.LayoutVersion != engine.DrawLayoutVersion:
+		return fmt.Errorf("spin was recorded with draw layout %d, server uses %d", r.LayoutVersion, engine.DrawLayoutVersion)
+	}
+
+	return nil
+}
+
+// Matches reports whether a replayed spin is the recorded one, from the base
+// window to the last free spin and cascade step
+func (r *Record) Matches(spin *engine.Spin) bool {
+	return bytes.Equal(r.Outcome, outcome(spin))
+}
+
+// outcome encodes everything the player saw of the spin, the draws are
+// recorded on their own
+func outcome(spin *engine.Spin) json.RawMessage {
+	played := *spin
+	played.Draws = nil
+
+	// The spin only holds plain data, which always marshals
+	data, err := json.Marshal(&played)
+	if err != nil {
+		panic(fmt.Sprintf("can not encode spin: %v", err))
+	}
+
+	return data
+}
+
+func NewID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("can not generate spin id: %v", err))
+	}
+
+	return hex.EncodeToString(buf)
+}
+
+// Store keeps records of played spins
+type Store interface {
+	Save(record *Record) error
+	Get(id string) (*Record, error)
+}
+
+// DefaultCapacity is the number of spin records the default store keeps
+const DefaultCapacity = 100_000
+
+// MemoryStore keeps the last spin records in memory
+type MemoryStore struct {
+	mu       sync.RWMutex
+	records  map[string]*Record
+	capacity int
+	order    []string // ids by age when capped, next is the oldest
+	next     int
+}
+
+// NewMemoryStore returns a store of the last capacity records, all records
+// are kept if capacity is zero
+func NewMemoryStore(capacity int) *MemoryStore {
+	return &MemoryStore{
+		records:  make(map[string]*Record),
+		capacity: capacity,
+	}
+}
+
+func (s *MemoryStore) Save(record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.records[record.ID]; !ok && s.capacity > 0 {
+		if len(s.order) < s.capacity {
+			s.order = append(s.order, record.ID)
+		} else {
+			delete(s.records, s.order[s.next])
+			s.order[s.next] = record.ID
+			s.next = (s.next + 1) % s.capacity
+		}
+	}
+
+	s.records[record.ID] = record
+
+	return nil
+}
+
+func (s *MemoryStore) Get(id string) (*Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return record, nil
+}
+
+// FileStore appends every spin record to a JSON lines file and keeps the
+// last DefaultCapacity records in memory, they are read back on start
+type FileStore struct {
+	*MemoryStore
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spin history: %w", err)
+	}
+
+	s := &FileStore{
+		MemoryStore: NewMemoryStore(DefaultCapacity),
+		file:        file,
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		record := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("corrupted spin history %s at line %d: %w", path, line, err)
+		}
+
+		s.MemoryStore.Save(record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read spin history: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) Save(record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write spin history: %w", err)
+	}
+
+	return s.MemoryStore.Save(record)
+}
+
+func (s *FileStore) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,104 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"piggy-bank/internal/engine"
+	"piggy-bank/internal/rng"
+)
+
+func TestMemoryStoreCapacity(t *testing.T) {
+	store := NewMemoryStore(2)
+
+	for i := 0; i < 3; i++ {
+		if err := store.Save(&Record{ID: fmt.Sprint(i)}); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	// Saving a kept record again does not evict another one
+	if err := store.Save(&Record{ID: "2"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if _, err := store.Get("0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(0) error = %v, want ErrNotFound", err)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		if _, err := store.Get(id); err != nil {
+			t.Errorf("Get(%s) error = %v", id, err)
+		}
+	}
+}
+
+func TestRecordCheckReplay(t *testing.T) {
+	game := engine.DefaultGame()
+	hash := game.Hash()
+
+	record := &Record{Game: game.Name, GameHash: hash, LayoutVersion: engine.DrawLayoutVersion}
+	if err := record.CheckReplay(game.Name, hash); err != nil {
+		t.Errorf("CheckReplay() error = %v", err)
+	}
+
+	changed := engine.DefaultGame()
+	changed.MaxFreeSpins++
+	if changed.Hash() == hash {
+		t.Error("Hash() did not change with the game")
+	}
+
+	if err := record.CheckReplay(game.Name, changed.Hash()); err == nil {
+		t.Error("CheckReplay() on another version of the game should fail")
+	}
+
+	record.LayoutVersion = 0
+	if err := record.CheckReplay(game.Name, hash); err == nil {
+		t.Error("CheckReplay() of another draw layout should fail")
+	}
+}
+
+func TestRecordMatches(t *testing.T) {
+	game := engine.DefaultGame()
+	factory := engine.NewSpinFactoryFromGame(game, rng.NewSeededClient(42))
+
+	// The base window of a spin with free spins tells nothing about them
+	var spin *engine.Spin
+	for i := 0; i < 100_000 && (spin == nil || len(spin.FreeSpins) == 0); i++ {
+		var err error
+		if spin, err = factory.GenerateRecorded(100); err != nil {
+			t.Fatalf("GenerateRecorded() error = %v", err)
+		}
+	}
+
+	if len(spin.FreeSpins) == 0 {
+		t.Fatal("no spin with free spins")
+	}
+
+	// Read the record back the way FileStore does
+	data, err := json.Marshal(NewRecord(NewID(), game.Name, game.Hash(), "", spin))
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	record := &Record{}
+	if err := json.Unmarshal(data, record); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	replayed, err := factory.Replay(record.Wager, record.Draws)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+
+	if !record.Matches(replayed) {
+		t.Error("Matches() = false for the replay of the recorded spin")
+	}
+
+	replayed.FreeSpins[len(replayed.FreeSpins)-1].Award++
+	if record.Matches(replayed) {
+		t.Error("Matches() = true with a different free spin award")
+	}
+}
@@ -0,0 +1,134 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// Sheet is a table of a report, written as a worksheet or a CSV file
+type Sheet struct {
+	Name   string
+	Header []string
+	Rows   [][]any
+}
+
+// NewSheet builds a sheet from the fields of v tagged with `xlsx:"Column"`,
+// fields tagged `xlsx:"-"` or without the tag are skipped. A slice of structs
+// becomes a table with a row per element, a single struct becomes a
+// two column table with a row per field.
+func NewSheet(name string, v any) (Sheet, error) {
+	value := reflect.Indirect(reflect.ValueOf(v))
+
+	switch value.Kind() {
+	case reflect.Struct:
+		sheet := Sheet{Name: name, Header: []string{"Field", "Value"}}
+		columns := taggedFields(value.Type())
+		for _, column := range columns {
+			sheet.Rows = append(sheet.Rows, []any{column.name, cellValue(value.Field(column.index))})
+		}
+		return sheet, nil
+
+	case reflect.Slice, reflect.Array:
+		elem := value.Type().Elem()
+		if elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+		if elem.Kind() != reflect.Struct {
+			return Sheet{}, fmt.Errorf("sheet %s: slice of %s is not a slice of structs", name, elem)
+		}
+
+		sheet := Sheet{Name: name}
+		columns := taggedFields(elem)
+		for _, column := range columns {
+			sheet.Header = append(sheet.Header, column.name)
+		}
+
+		for i := 0; i < value.Len(); i++ {
+			row := reflect.Indirect(value.Index(i))
+			cells := make([]any, len(columns))
+			if row.IsValid() {
+				for j, column := range columns {
+					cells[j] = cellValue(row.Field(column.index))
+				}
+			}
+			sheet.Rows = append(sheet.Rows, cells)
+		}
+		return sheet, nil
+	}
+
+	return Sheet{}, errors.New("sheet " + name + ": value must be a struct or a slice of structs")
+}
+
+type taggedField struct {
+	name  string
+	index int
+}
+
+func taggedFields(t reflect.Type) []taggedField {
+	var fields []taggedField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("xlsx")
+		if !ok || tag == "-" || !field.IsExported() {
+			continue
+		}
+		fields = append(fields, taggedField{name: tag, index: i})
+	}
+
+	return fields
+}
+
+// maxExactInt is the largest integer spreadsheets hold exactly as a number
+const maxExactInt = 1 << 53
+
+// cellValue converts a field to a number, bool or string cell. Strings holding a plain
+// number, like the formatted values of the views, become numbers too.
+func cellValue(v reflect.Value) any {
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return ""
+	}
+
+	switch value := v.Interface().(type) {
+	case *big.Int:
+		return integerCell(value)
+	case *big.Float:
+		f, _ := value.Float64()
+		return f
+	case fmt.Stringer:
+		return value.String()
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return integerCell(big.NewInt(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return integerCell(new(big.Int).SetUint64(v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.String:
+		if i, ok := new(big.Int).SetString(v.String(), 10); ok {
+			return integerCell(i)
+		}
+		if f, err := strconv.ParseFloat(v.String(), 64); err == nil {
+			return f
+		}
+		return v.String()
+	}
+
+	return fmt.Sprint(v.Interface())
+}
+
+// integerCell returns an integer as a number cell when spreadsheets hold it
+// exactly and as its digits otherwise, so big sums keep every digit
+func integerCell(i *big.Int) any {
+	if i.IsInt64() && i.Int64() <= maxExactInt && i.Int64() >= -maxExactInt {
+		return i.Int64()
+	}
+
+	return i.String()
+}
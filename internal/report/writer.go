@@ -0,0 +1,209 @@
+package report
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxSheetName is the longest worksheet name spreadsheet applications accept
+const maxSheetName = 31
+
+// WriteCSV writes the header and rows of a sheet as CSV
+func WriteCSV(w io.Writer, sheet Sheet) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(sheet.Header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(sheet.Header))
+	for _, row := range sheet.Rows {
+		record = record[:0]
+		for _, cell := range row {
+			record = append(record, formatCell(cell))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// WriteXLSX writes the sheets as an Office Open XML workbook with a worksheet per sheet
+func WriteXLSX(w io.Writer, sheets []Sheet) error {
+	if len(sheets) == 0 {
+		return fmt.Errorf("workbook needs at least one sheet")
+	}
+
+	archive := zip.NewWriter(w)
+
+	names := make([]string, len(sheets))
+	for i, sheet := range sheets {
+		names[i] = sheetName(sheet.Name, i)
+	}
+
+	var contentTypes, workbook, workbookRels strings.Builder
+
+	contentTypes.WriteString(xml.Header)
+	contentTypes.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	contentTypes.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	contentTypes.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	contentTypes.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+
+	workbook.WriteString(xml.Header)
+	workbook.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+
+	workbookRels.WriteString(xml.Header)
+	workbookRels.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+
+	for i, name := range names {
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(name), i+1, i+1)
+		fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	workbookRels.WriteString(`</Relationships>`)
+
+	rootRels := xml.Header +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+
+	files := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypes.String()},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", workbookRels.String()},
+	}
+
+	for _, file := range files {
+		if err := writeZipFile(archive, file.name, file.content); err != nil {
+			return err
+		}
+	}
+
+	for i, sheet := range sheets {
+		if err := writeZipFile(archive, fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), worksheet(sheet)); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func writeZipFile(archive *zip.Writer, name, content string) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to workbook: %w", name, err)
+	}
+
+	_, err = io.WriteString(file, content)
+
+	return err
+}
+
+func worksheet(sheet Sheet) string {
+	var b strings.Builder
+
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	writeRow := func(index int, cells []any) {
+		fmt.Fprintf(&b, `<row r="%d">`, index)
+		for col, cell := range cells {
+			ref := columnName(col) + strconv.Itoa(index)
+			switch value := cell.(type) {
+			case int64, uint64:
+				fmt.Fprintf(&b, `<c r="%s"><v>%d</v></c>`, ref, value)
+			case float64:
+				if math.IsNaN(value) || math.IsInf(value, 0) {
+					fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, formatCell(value))
+				} else {
+					fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, formatCell(value))
+				}
+			case bool:
+				v := 0
+				if value {
+					v = 1
+				}
+				fmt.Fprintf(&b, `<c r="%s" t="b"><v>%d</v></c>`, ref, v)
+			default:
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, escape(formatCell(value)))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+
+	header := make([]any, len(sheet.Header))
+	for i, name := range sheet.Header {
+		header[i] = name
+	}
+	writeRow(1, header)
+
+	for i, row := range sheet.Rows {
+		writeRow(i+2, row)
+	}
+
+	b.WriteString(`</sheetData></worksheet>`)
+
+	return b.String()
+}
+
+// columnName returns the spreadsheet column letters of a zero based index
+func columnName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+
+	return name
+}
+
+// sheetName removes the characters worksheet names can not hold and truncates them
+func sheetName(name string, index int) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+
+	if name == "" {
+		name = fmt.Sprintf("Sheet%d", index+1)
+	}
+
+	if runes := []rune(name); len(runes) > maxSheetName {
+		name = string(runes[:maxSheetName])
+	}
+
+	return name
+}
+
+func formatCell(cell any) string {
+	switch value := cell.(type) {
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case string:
+		return value
+	}
+
+	return fmt.Sprint(cell)
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+
+	return b.String()
+}
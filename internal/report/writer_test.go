@@ -0,0 +1,133 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+type row struct {
+	Name   string   `xlsx:"Name"`
+	Count  int64    `xlsx:"Count"`
+	Rate   string   `xlsx:"Rate"`
+	Award  *big.Int `xlsx:"Award"`
+	Hidden string   `xlsx:"-"`
+	Plain  string
+}
+
+func TestNewSheet(t *testing.T) {
+	sheet, err := NewSheet("Rows", []row{{Name: "a", Count: 2, Rate: "1.500", Award: big.NewInt(7), Hidden: "x"}})
+	if err != nil {
+		t.Fatalf("NewSheet() error = %v", err)
+	}
+
+	if got := strings.Join(sheet.Header, ","); got != "Name,Count,Rate,Award" {
+		t.Errorf("header = %v, want Name,Count,Rate,Award", got)
+	}
+
+	want := []any{"a", int64(2), 1.5, int64(7)}
+	for i, cell := range sheet.Rows[0] {
+		if cell != want[i] {
+			t.Errorf("cell %d = %#v, want %#v", i, cell, want[i])
+		}
+	}
+
+	summary, err := NewSheet("Summary", &row{Name: "b"})
+	if err != nil {
+		t.Fatalf("NewSheet() error = %v", err)
+	}
+
+	if len(summary.Rows) != 4 || summary.Rows[0][0] != "Name" || summary.Rows[0][1] != "b" {
+		t.Errorf("summary rows = %v, want a row per tagged field", summary.Rows)
+	}
+
+	if _, err := NewSheet("Invalid", []int{1}); err == nil {
+		t.Error("NewSheet() of a slice of ints should fail")
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	sheet, _ := NewSheet("Rows <1>", []row{{Name: "a&b", Count: 3}})
+
+	var buf bytes.Buffer
+	if err := WriteXLSX(&buf, []Sheet{sheet}); err != nil {
+		t.Fatalf("WriteXLSX() error = %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("workbook is not a zip archive: %v", err)
+	}
+
+	files := make(map[string]string)
+	for _, file := range archive.File {
+		r, _ := file.Open()
+		data, _ := io.ReadAll(r)
+		r.Close()
+		files[file.Name] = string(data)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("workbook is missing %s", name)
+		}
+	}
+
+	if !strings.Contains(files["xl/workbook.xml"], `name="Rows &lt;1&gt;"`) {
+		t.Errorf("workbook.xml does not name the sheet: %s", files["xl/workbook.xml"])
+	}
+
+	sheetXML := files["xl/worksheets/sheet1.xml"]
+	for _, cell := range []string{`<c r="A2" t="inlineStr"><is><t>a&amp;b</t></is></c>`, `<c r="B2"><v>3</v></c>`} {
+		if !strings.Contains(sheetXML, cell) {
+			t.Errorf("sheet1.xml is missing %s", cell)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	sheet, _ := NewSheet("Rows", []row{{Name: "a,b", Count: 1, Rate: "0.5%"}})
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, sheet); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+
+	want := "Name,Count,Rate,Award\n\"a,b\",1,0.5%,\n"
+	if buf.String() != want {
+		t.Errorf("WriteCSV() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestLargeIntegers(t *testing.T) {
+	award, _ := new(big.Int).SetString("123456789012345678901", 10)
+	sheet, _ := NewSheet("Rows", []row{{Name: "a", Count: 1<<53 + 1, Rate: "9007199254740993", Award: award}})
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, sheet); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+
+	want := "Name,Count,Rate,Award\na,9007199254740993,9007199254740993,123456789012345678901\n"
+	if buf.String() != want {
+		t.Errorf("WriteCSV() = %q, want %q", buf.String(), want)
+	}
+
+	sheetXML := worksheet(sheet)
+	for _, cell := range []string{`<c r="B2" t="inlineStr"><is><t>9007199254740993</t></is></c>`, `<c r="D2" t="inlineStr"><is><t>123456789012345678901</t></is></c>`} {
+		if !strings.Contains(sheetXML, cell) {
+			t.Errorf("worksheet is missing %s", cell)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for index, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(index); got != want {
+			t.Errorf("columnName(%d) = %s, want %s", index, got, want)
+		}
+	}
+}
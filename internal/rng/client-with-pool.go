@@ -3,7 +3,9 @@ package rng
 import (
 	"container/list"
 	"context"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"piggy-bank/config"
@@ -14,91 +16,179 @@ import (
 const (
 	poolSize = 16
 	ringSize = 128
+
+	// refillThreshold is the number of buffered values below which a buffer
+	// is refilled in the background
+	refillThreshold = ringSize / 4
 )
 
-// test about memmory leaks.
+// WithPoolClient buffers random numbers per max value. Buffers are refilled in
+// the background before they run dry, a request for an empty buffer fetches
+// a new one synchronously. It is safe for concurrent use.
 type WithPoolClient struct {
-	// think about blocking optimization
-	mu                sync.Mutex
-	uintPool          *list.List
+	mu      sync.Mutex
+	buffers map[uint64]*list.Element // of *Node, most recently used first
+	lru     *list.List
+
 	api               RNGClient
 	MaxProcessingTime time.Duration
+
+	hits          atomic.Int64
+	misses        atomic.Int64
+	refills       atomic.Int64
+	refillErrors  atomic.Int64
+	refillLatency atomic.Int64 // total, in nanoseconds
 }
 
 type Node struct {
-	max  uint64
-	ring *Ring[uint64]
+	max       uint64
+	ring      *Ring[uint64]
+	refilling atomic.Bool
 }
 
-func NewWithPoolClient(cfg *config.Config) (Client, error) {
-	var err error
+// PoolStats are the counters of a WithPoolClient
+type PoolStats struct {
+	Hits          int64
+	Misses        int64
+	Refills       int64
+	RefillErrors  int64
+	RefillLatency time.Duration // average latency of a refill request
+}
 
-	client := &WithPoolClient{uintPool: list.New()}
-	client.api, err = newClient(cfg.RNG.Host, cfg.RNG.Port, cfg.RNG.IsSecure)
+func NewWithPoolClient(cfg *config.Config) (Client, error) {
+	api, err := newClient(cfg.RNG.Host, cfg.RNG.Port, cfg.RNG.IsSecure)
 	if err != nil {
 		zap.S().Debug(err)
 
 		return nil, err
 	}
 
-	client.MaxProcessingTime = cfg.RNG.MaxProcessingTime
+	return newWithPoolClient(api, cfg.RNG.MaxProcessingTime), nil
+}
+
+func newWithPoolClient(api RNGClient, maxProcessingTime time.Duration) *WithPoolClient {
+	return &WithPoolClient{
+		buffers:           make(map[uint64]*list.Element),
+		lru:               list.New(),
+		api:               api,
+		MaxProcessingTime: maxProcessingTime,
+	}
+}
+
+// Stats returns the buffer hits and misses and the refill counters
+func (c *WithPoolClient) Stats() PoolStats {
+	stats := PoolStats{
+		Hits:         c.hits.Load(),
+		Misses:       c.misses.Load(),
+		Refills:      c.refills.Load(),
+		RefillErrors: c.refillErrors.Load(),
+	}
+
+	if stats.Refills > 0 {
+		stats.RefillLatency = time.Duration(c.refillLatency.Load() / stats.Refills)
+	}
 
-	return client, nil
+	return stats
 }
 
-func (c *WithPoolClient) getNewPool(value uint64) ([]uint64, error) {
+// node returns the buffer of max, creating it and evicting the least
+// recently used buffer when the pool is full
+func (c *WithPoolClient) node(max uint64) *Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.buffers[max]; ok {
+		c.lru.MoveToFront(elem)
+
+		return elem.Value.(*Node)
+	}
+
+	if c.lru.Len() >= poolSize {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.buffers, oldest.Value.(*Node).max)
+	}
+
+	node := &Node{max: max, ring: NewRing[uint64](ringSize)}
+	c.buffers[max] = c.lru.PushFront(node)
+
+	return node
+}
+
+// fetch requests count random numbers below max and records the refill latency
+func (c *WithPoolClient) fetch(max uint64, count int) ([]uint64, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), c.MaxProcessingTime)
 	defer cancel()
 
-	resp, err := c.api.Rand(ctx, &RandRequest{Max: sliceOfValues(value, ringSize)})
+	start := time.Now()
+
+	resp, err := c.api.Rand(ctx, &RandRequest{Max: sliceOfValues(max, count)})
 	if err != nil {
+		c.refillErrors.Add(1)
+
 		return nil, err
 	}
 
+	c.refills.Add(1)
+	c.refillLatency.Add(int64(time.Since(start)))
+
+	if len(resp.Result) != count {
+		return nil, fmt.Errorf("RNG returned %d random numbers, requested %d", len(resp.Result), count)
+	}
+
 	return resp.Result, nil
 }
 
-func (c *WithPoolClient) getUint(max uint64) (rand uint64, err error) {
-	var (
-		isFound = false
-		node    *Node
-	)
-
-	for i := c.uintPool.Front(); i != c.uintPool.Back(); i = i.Next() {
-		if node = i.Value.(*Node); node.max == max {
-			isFound = true
+// prefetch refills the buffer in the background once it runs low,
+// at most one refill per buffer is in flight
+func (c *WithPoolClient) prefetch(node *Node) {
+	if node.ring.Len() >= refillThreshold || !node.refilling.CompareAndSwap(false, true) {
+		return
+	}
 
-			c.uintPool.MoveToFront(i)
+	go func() {
+		defer node.refilling.Store(false)
 
-			break
+		free := node.ring.Cap() - node.ring.Len()
+		if free <= 0 {
+			return
 		}
-	}
 
-	if isFound {
-		rand, ok := node.ring.Read()
-		if ok {
-			return rand, nil
+		values, err := c.fetch(node.max, free)
+		if err != nil {
+			zap.S().Debugf("can not refill pool of %d: %v", node.max, err)
+
+			return
 		}
-	} else {
-		if c.uintPool.Len() == poolSize {
-			c.uintPool.Remove(c.uintPool.Back())
+
+		for _, value := range values {
+			node.ring.Write(value)
 		}
-		node = &Node{max: max, ring: NewRing[uint64](ringSize)}
-		c.uintPool.PushFront(node)
+	}()
+}
+
+func (c *WithPoolClient) getUint(max uint64) (rand uint64, err error) {
+	node := c.node(max)
+
+	if rand, ok := node.ring.Read(); ok {
+		c.hits.Add(1)
+		c.prefetch(node)
+
+		return rand, nil
 	}
 
-	resp, err := c.getNewPool(max)
+	c.misses.Add(1)
+
+	values, err := c.fetch(max, ringSize)
 	if err != nil {
 		return 0, err
 	}
 
-	rand = resp[0]
-
-	for i := 1; i < len(resp); i++ {
-		node.ring.Write(resp[i])
+	for _, value := range values[1:] {
+		node.ring.Write(value)
 	}
 
-	return rand, nil
+	return values[0], nil
 }
 
 func (c *WithPoolClient) Rand(max uint64) (rand uint64, err error) {
@@ -0,0 +1,96 @@
+package rng
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// countingAPI answers Rand requests with max-1 and counts them
+type countingAPI struct {
+	RNGClient
+	calls atomic.Int64
+}
+
+func (a *countingAPI) Rand(ctx context.Context, in *RandRequest, opts ...grpc.CallOption) (*RandResponse, error) {
+	a.calls.Add(1)
+
+	result := make([]uint64, len(in.Max))
+	for i, max := range in.Max {
+		result[i] = max - 1
+	}
+
+	return &RandResponse{Result: result}, nil
+}
+
+func TestWithPoolClientConcurrent(t *testing.T) {
+	api := &countingAPI{}
+	client := newWithPoolClient(api, time.Second)
+
+	const (
+		workers = 16
+		draws   = 2000
+	)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < draws; i++ {
+				// More distinct maxes than buffers, so buffers get evicted too
+				max := uint64(2 + (w+i)%(poolSize+4))
+				got, err := client.Rand(max)
+				if err != nil {
+					t.Errorf("Rand(%d) error = %v", max, err)
+					return
+				}
+				if got != max-1 {
+					t.Errorf("Rand(%d) = %d, value of another buffer", max, got)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	stats := client.Stats()
+	if stats.Hits+stats.Misses != workers*draws {
+		t.Errorf("hits %d + misses %d, want %d draws", stats.Hits, stats.Misses, workers*draws)
+	}
+
+	if stats.Refills+stats.RefillErrors < stats.Misses {
+		t.Errorf("refills = %d, fewer than misses %d", stats.Refills, stats.Misses)
+	}
+}
+
+func TestWithPoolClientPrefetch(t *testing.T) {
+	api := &countingAPI{}
+	client := newWithPoolClient(api, time.Second)
+
+	// The first draw misses, later ones are served while the buffer is refilled in the background
+	for i := 0; i < 10*ringSize; i++ {
+		if _, err := client.Rand(6); err != nil {
+			t.Fatalf("Rand() error = %v", err)
+		}
+
+		if i%(refillThreshold/2) == 0 {
+			// Give a background refill time to land before the buffer runs dry
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	stats := client.Stats()
+	if stats.Misses != 1 {
+		t.Errorf("misses = %d, want 1 with background refills", stats.Misses)
+	}
+
+	if stats.Hits != 10*ringSize-1 {
+		t.Errorf("hits = %d, want %d", stats.Hits, 10*ringSize-1)
+	}
+}
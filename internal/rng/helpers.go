@@ -10,7 +10,10 @@ import (
 )
 
 func newClient(host, port string, isSecure bool) (RNGClient, error) {
-	addr := host + ":" + port
+	return dial(host+":"+port, isSecure)
+}
+
+func dial(addr string, isSecure bool) (RNGClient, error) {
 	var (
 		conn *grpc.ClientConn
 		err  error
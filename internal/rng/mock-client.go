@@ -2,6 +2,7 @@ package rng
 
 import (
 	rnd "crypto/rand"
+	"errors"
 	"math/big"
 
 	"piggy-bank/config"
@@ -14,24 +15,19 @@ func NewMockClient(cfg *config.Config) (Client, error) {
 }
 
 func (c *MockClient) Rand(max uint64) (rand uint64, err error) {
-	res, err := rnd.Int(rnd.Reader, big.NewInt(int64(max)))
-	if err != nil {
-		return 0, err
-	}
-
-	return res.Uint64(), nil
+	return randBelow(max)
 }
 
 func (c *MockClient) RandSlice(maxSlice []uint64) (rand []uint64, err error) {
 	rand = make([]uint64, 0, len(maxSlice))
 
 	for _, max := range maxSlice {
-		res, err := rnd.Int(rnd.Reader, big.NewInt(int64(max)))
+		res, err := randBelow(max)
 		if err != nil {
 			return nil, err
 		}
 
-		rand = append(rand, res.Uint64())
+		rand = append(rand, res)
 	}
 
 	return rand, nil
@@ -58,3 +54,17 @@ func (c *MockClient) RandFloatSlice(count int) (rand []float64, err error) {
 
 	return rand, nil
 }
+
+// randBelow draws from crypto/rand in [0, max), max may use all 64 bits
+func randBelow(max uint64) (uint64, error) {
+	if max == 0 {
+		return 0, errors.New("max must be positive")
+	}
+
+	res, err := rnd.Int(rnd.Reader, new(big.Int).SetUint64(max))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.Uint64(), nil
+}
@@ -52,3 +52,16 @@ func (r *Ring[T]) Write(toWrite T) bool {
 
 	return true
 }
+
+// Len returns the number of values waiting to be read
+func (r *Ring[T]) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return (r.write - r.read + r.len) % r.len
+}
+
+// Cap returns the number of values the ring can hold, one slot is kept free
+func (r *Ring[T]) Cap() int {
+	return r.len - 1
+}
@@ -0,0 +1,92 @@
+package rng
+
+import (
+	"math/rand/v2"
+	"sync"
+)
+
+// SeededClient is a deterministic PCG based client. The same seed always
+// produces the same sequence, which makes spins and simulations replayable.
+// It is not a certified RNG and must not be used for real money play.
+type SeededClient struct {
+	mu   sync.Mutex
+	seed uint64
+	pcg  *rand.PCG
+	rnd  *rand.Rand
+}
+
+func NewSeededClient(seed uint64) *SeededClient {
+	return newSeededClient(seed, splitMix64(seed), splitMix64(^seed))
+}
+
+func newSeededClient(seed, hi, lo uint64) *SeededClient {
+	pcg := rand.NewPCG(hi, lo)
+
+	return &SeededClient{
+		seed: seed,
+		pcg:  pcg,
+		rnd:  rand.New(pcg),
+	}
+}
+
+// Derive returns an independent client for the given stream. Derived clients
+// depend only on the seed and the stream, not on draws made by the parent.
+func (c *SeededClient) Derive(stream uint64) *SeededClient {
+	mixed := splitMix64(c.seed ^ splitMix64(stream+1))
+
+	return newSeededClient(c.seed, splitMix64(mixed), splitMix64(^mixed))
+}
+
+func (c *SeededClient) Seed() uint64 {
+	return c.seed
+}
+
+func (c *SeededClient) Rand(max uint64) (rand uint64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.rnd.Uint64N(max), nil
+}
+
+func (c *SeededClient) RandSlice(maxSlice []uint64) (rand []uint64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rand = make([]uint64, 0, len(maxSlice))
+	for _, max := range maxSlice {
+		rand = append(rand, c.rnd.Uint64N(max))
+	}
+
+	return rand, nil
+}
+
+func (c *SeededClient) RandFloat() (float64, error) {
+	rand, err := c.Rand(1 << 53)
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(rand) / (1 << 53), nil
+}
+
+func (c *SeededClient) RandFloatSlice(count int) (rand []float64, err error) {
+	for i := 0; i < count; i++ {
+		res, err := c.RandFloat()
+		if err != nil {
+			return nil, err
+		}
+
+		rand = append(rand, res)
+	}
+
+	return rand, nil
+}
+
+// splitMix64 spreads nearby seeds and stream numbers over the PCG state space
+func splitMix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+
+	return x ^ (x >> 31)
+}
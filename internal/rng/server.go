@@ -0,0 +1,95 @@
+package rng
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxRequestSize is the largest number of random values served per request
+const maxRequestSize = 1 << 16
+
+// Server implements the RNG gRPC service on top of a Client, such as a
+// MockClient backed by crypto/rand or a SeededClient. It lets the gRPC
+// clients run without an external RNG service, it is not a certified RNG.
+type Server struct {
+	UnimplementedRNGServer
+
+	client Client
+}
+
+// NewServer creates a server drawing from crypto/rand
+func NewServer() *Server {
+	return NewServerWithClient(&MockClient{})
+}
+
+// NewSeededServer creates a server producing the reproducible sequence of seed
+func NewSeededServer(seed uint64) *Server {
+	return NewServerWithClient(NewSeededClient(seed))
+}
+
+func NewServerWithClient(client Client) *Server {
+	return &Server{client: client}
+}
+
+// GRPCServer creates a gRPC server serving s, ready to Serve a listener
+func (s *Server) GRPCServer() *grpc.Server {
+	server := grpc.NewServer()
+	RegisterRNGServer(server, s)
+
+	return server
+}
+
+func (s *Server) Rand(_ context.Context, in *RandRequest) (*RandResponse, error) {
+	if len(in.GetMax()) > maxRequestSize {
+		return nil, status.Errorf(codes.InvalidArgument, "requested %d random numbers, at most %d are served", len(in.GetMax()), maxRequestSize)
+	}
+
+	for _, max := range in.GetMax() {
+		if max == 0 {
+			return nil, status.Error(codes.InvalidArgument, "max must be positive")
+		}
+	}
+
+	result, err := s.client.RandSlice(in.GetMax())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "can not rand: %v", err)
+	}
+
+	return &RandResponse{Result: result}, nil
+}
+
+// RandFloat returns Max random floats in [0, 1)
+func (s *Server) RandFloat(_ context.Context, in *RandRequestFloat) (*RandResponseFloat, error) {
+	if in.GetMax() == 0 || in.GetMax() > maxRequestSize {
+		return nil, status.Errorf(codes.InvalidArgument, "requested %d random floats, between 1 and %d are served", in.GetMax(), maxRequestSize)
+	}
+
+	result, err := s.client.RandFloatSlice(int(in.GetMax()))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "can not rand float: %v", err)
+	}
+
+	return &RandResponseFloat{Result: result}, nil
+}
+
+// HealthCheck answers every ping with StatusServing until the client closes the stream
+func (s *Server) HealthCheck(stream grpc.BidiStreamingServer[Status, Status]) error {
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+
+		if err := stream.Send(&Status{Status: StatusServing}); err != nil {
+			return err
+		}
+	}
+}
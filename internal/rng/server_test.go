@@ -0,0 +1,151 @@
+package rng
+
+import (
+	"context"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startServer serves srv over an in-memory listener and returns a gRPC client of it
+func startServer(t *testing.T, srv *Server) RNGClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	server := srv.GRPCServer()
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return NewRNGClient(conn)
+}
+
+func TestServerClients(t *testing.T) {
+	api := startServer(t, NewServer())
+
+	clients := map[string]Client{
+		"simple": newSimpleClient(api, time.Second),
+		"pool":   newWithPoolClient(api, time.Second),
+	}
+
+	for name, client := range clients {
+		t.Run(name, func(t *testing.T) {
+			for _, max := range []uint64{1, 6, 81, 100} {
+				for i := 0; i < 2*ringSize; i++ {
+					got, err := client.Rand(max)
+					if err != nil {
+						t.Fatalf("Rand(%d) error = %v", max, err)
+					}
+					if got >= max {
+						t.Fatalf("Rand(%d) = %d, out of range", max, got)
+					}
+				}
+			}
+
+			maxSlice := []uint64{81, 93, 96, 100, 2}
+			got, err := client.RandSlice(maxSlice)
+			if err != nil {
+				t.Fatalf("RandSlice() error = %v", err)
+			}
+			if len(got) != len(maxSlice) {
+				t.Fatalf("RandSlice() returned %d values, want %d", len(got), len(maxSlice))
+			}
+			for i, max := range maxSlice {
+				if got[i] >= max {
+					t.Errorf("RandSlice()[%d] = %d, out of range of %d", i, got[i], max)
+				}
+			}
+
+			floats, err := client.RandFloatSlice(10)
+			if err != nil {
+				t.Fatalf("RandFloatSlice() error = %v", err)
+			}
+			for _, f := range floats {
+				if f < 0 || f >= 1 {
+					t.Errorf("RandFloatSlice() value %v out of [0, 1)", f)
+				}
+			}
+		})
+	}
+}
+
+func TestSeededServerReproducible(t *testing.T) {
+	draw := func() []uint64 {
+		client := newSimpleClient(startServer(t, NewSeededServer(42)), time.Second)
+
+		values, err := client.RandSlice([]uint64{81, 93, 96, 100})
+		if err != nil {
+			t.Fatalf("RandSlice() error = %v", err)
+		}
+
+		return values
+	}
+
+	first, second := draw(), draw()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("seeded servers drew %v and %v", first, second)
+		}
+	}
+}
+
+func TestServerRejectsInvalidRequests(t *testing.T) {
+	client := newSimpleClient(startServer(t, NewServer()), time.Second)
+
+	if _, err := client.Rand(0); err == nil {
+		t.Error("Rand(0) should fail")
+	}
+
+	if _, err := client.RandFloatSlice(maxRequestSize + 1); err == nil {
+		t.Error("RandFloatSlice() above the request size should fail")
+	}
+}
+
+func TestServerFullRange(t *testing.T) {
+	for name, srv := range map[string]*Server{"crypto": NewServer(), "seeded": NewSeededServer(42)} {
+		t.Run(name, func(t *testing.T) {
+			client := newSimpleClient(startServer(t, srv), time.Second)
+
+			maxSlice := []uint64{1 << 63, math.MaxUint64}
+			got, err := client.RandSlice(maxSlice)
+			if err != nil {
+				t.Fatalf("RandSlice(%v) error = %v", maxSlice, err)
+			}
+
+			for i, max := range maxSlice {
+				if got[i] >= max {
+					t.Errorf("RandSlice()[%d] = %d, out of range of %d", i, got[i], max)
+				}
+			}
+		})
+	}
+}
+
+func TestServerHealthCheck(t *testing.T) {
+	api := startServer(t, NewServer())
+
+	s := newSupervisor([]*backend{newBackend("bufnet", api, newSimpleClient(api, time.Second))},
+		Options{HealthInterval: time.Millisecond})
+	defer s.Close()
+
+	// The backend starts healthy, an unanswered stream would turn it unhealthy
+	time.Sleep(50 * time.Millisecond)
+	if !s.Healthy()["bufnet"] {
+		t.Error("backend is unhealthy with a serving health stream")
+	}
+}
@@ -1,51 +1,222 @@
 package rng
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"time"
 
 	"piggy-bank/config"
 )
 
 type Service struct {
-	client Client
+	client     Client
+	seeded     *SeededClient
+	supervisor *Supervisor
+}
+
+// Options configure how the service reaches the RNG servers
+type Options struct {
+	// SecondaryAddr is the host:port of an RNG server to fail over to
+	SecondaryAddr string
+	// Strict makes a failure to set up the RNG an error instead of a
+	// fallback to the non-certified MockClient
+	Strict bool
+
+	// HealthInterval is the time between pings on the health stream,
+	// HealthTimeout how long a ping may go unanswered and MaxBackoff the
+	// longest wait before reopening a broken stream. Zero uses the defaults.
+	HealthInterval time.Duration
+	HealthTimeout  time.Duration
+	MaxBackoff     time.Duration
 }
 
 func NewService(cfg *config.Config) (*Service, error) {
-	var (
-		client Client
-		err    error
-	)
+	return NewServiceWithOptions(cfg, Options{})
+}
+
+// NewServiceWithOptions creates a service that draws from the configured RNG
+// server, supervised by health checks and failing over to opts.SecondaryAddr
+func NewServiceWithOptions(cfg *config.Config, opts Options) (*Service, error) {
+	if cfg.RNG.UseMock {
+		log.Printf("UseMock: %v", cfg.RNG.UseMock)
 
-	useMock := cfg.RNG.UseMock
-	usePool := cfg.RNG.UsePool
+		client, err := NewMockClient(cfg)
+		if err != nil {
+			return nil, err
+		}
 
-	if useMock {
-		log.Printf("UseMock: %v", useMock)
-		client, err = NewMockClient(cfg)
-	} else if usePool {
-		log.Printf("UsePool: %v", usePool)
-		client, err = NewWithPoolClient(cfg)
-	} else {
-		client, err = NewSimpleClient(cfg)
+		return &Service{client: client}, nil
 	}
 
+	supervisor, err := newSupervisedClient(cfg, opts)
 	if err != nil {
-		log.Printf("Error creating RNG client: %v, using fallback MockClient", err)
-		client, err = NewMockClient(cfg)
+		if opts.Strict {
+			return nil, fmt.Errorf("error creating RNG client: %w", err)
+		}
+
+		log.Printf("Error creating RNG client: %v, using fallback MockClient, results are NOT certified", err)
+
+		client, err := NewMockClient(cfg)
 		if err != nil {
 			return nil, err
 		}
+
+		return &Service{client: client}, nil
 	}
 
 	return &Service{
-		client: client,
+		client:     supervisor,
+		supervisor: supervisor,
 	}, nil
 }
 
+// newSupervisedClient dials the primary and secondary RNG servers. Dialing
+// does not connect, so every server is probed first, the unreachable ones
+// start unhealthy and it fails when none answers.
+func newSupervisedClient(cfg *config.Config, opts Options) (*Supervisor, error) {
+	addrs := []string{cfg.RNG.Host + ":" + cfg.RNG.Port}
+	if opts.SecondaryAddr != "" {
+		addrs = append(addrs, opts.SecondaryAddr)
+	}
+
+	if cfg.RNG.UsePool {
+		log.Printf("UsePool: %v", cfg.RNG.UsePool)
+	}
+
+	var backends []*backend
+
+	for _, addr := range addrs {
+		api, err := dial(addr, cfg.RNG.IsSecure)
+		if err != nil {
+			return nil, err
+		}
+
+		var client Client
+		if cfg.RNG.UsePool {
+			client = newWithPoolClient(api, cfg.RNG.MaxProcessingTime)
+		} else {
+			client = newSimpleClient(api, cfg.RNG.MaxProcessingTime)
+		}
+
+		backends = append(backends, newBackend(addr, api, client))
+	}
+
+	timeout := opts.HealthTimeout
+	if timeout <= 0 {
+		timeout = defaultHealthTimeout
+	}
+
+	var errs []error
+
+	for _, b := range backends {
+		if err := probe(b.api, timeout); err != nil {
+			log.Printf("RNG backend %s is unhealthy: %v", b.addr, err)
+			b.healthy.Store(false)
+			errs = append(errs, fmt.Errorf("%s: %w", b.addr, err))
+		}
+	}
+
+	if len(errs) == len(backends) {
+		return nil, fmt.Errorf("%w: %w", ErrNoBackend, errors.Join(errs...))
+	}
+
+	return newSupervisor(backends, opts), nil
+}
+
+// NewSeededService creates a service backed by a deterministic SeededClient
+func NewSeededService(seed uint64) *Service {
+	log.Printf("Using seeded RNG with seed %d, results are reproducible and not certified", seed)
+
+	client := NewSeededClient(seed)
+
+	return &Service{
+		client: client,
+		seeded: client,
+	}
+}
+
 func (s *Service) GetClient() Client {
 	return s.client
 }
 
+// IsSeeded reports whether the service produces a reproducible sequence
+func (s *Service) IsSeeded() bool {
+	return s.seeded != nil
+}
+
+// Seed returns the seed of a seeded service
+func (s *Service) Seed() (uint64, bool) {
+	if s.seeded == nil {
+		return 0, false
+	}
+
+	return s.seeded.Seed(), true
+}
+
+// PoolStats returns the buffer counters, summed over the RNG servers, when the
+// service uses WithPoolClients
+func (s *Service) PoolStats() (PoolStats, bool) {
+	if s.supervisor == nil {
+		return PoolStats{}, false
+	}
+
+	var (
+		stats   PoolStats
+		latency time.Duration
+		pooled  bool
+	)
+
+	for _, b := range s.supervisor.backends {
+		pool, ok := b.client.(*WithPoolClient)
+		if !ok {
+			continue
+		}
+
+		pooled = true
+		backendStats := pool.Stats()
+
+		stats.Hits += backendStats.Hits
+		stats.Misses += backendStats.Misses
+		stats.Refills += backendStats.Refills
+		stats.RefillErrors += backendStats.RefillErrors
+		latency += backendStats.RefillLatency * time.Duration(backendStats.Refills)
+	}
+
+	if stats.Refills > 0 {
+		stats.RefillLatency = latency / time.Duration(stats.Refills)
+	}
+
+	return stats, pooled
+}
+
+// Healthy reports the health of every supervised RNG server by address
+func (s *Service) Healthy() map[string]bool {
+	if s.supervisor == nil {
+		return nil
+	}
+
+	return s.supervisor.Healthy()
+}
+
+// Close stops the health checks of the RNG servers
+func (s *Service) Close() {
+	if s.supervisor != nil {
+		s.supervisor.Close()
+	}
+}
+
+// Stream returns the client for an independent unit of work, such as a block of
+// simulated spins. Seeded services derive a separate reproducible stream for every
+// id, other services share their client.
+func (s *Service) Stream(id uint64) Client {
+	if s.seeded != nil {
+		return s.seeded.Derive(id)
+	}
+
+	return s.client
+}
+
 func (s *Service) Rand(max uint64) (uint64, error) {
 	return s.client.Rand(max)
 }
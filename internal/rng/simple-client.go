@@ -15,17 +15,19 @@ type SimpleClient struct {
 }
 
 func NewSimpleClient(cfg *config.Config) (Client, error) {
-	var err error
-
-	client := &SimpleClient{}
-	client.api, err = newClient(cfg.RNG.Host, cfg.RNG.Port, cfg.RNG.IsSecure)
+	api, err := newClient(cfg.RNG.Host, cfg.RNG.Port, cfg.RNG.IsSecure)
 	if err != nil {
 		return nil, err
 	}
 
-	client.MaxProcessingTime = cfg.RNG.MaxProcessingTime
+	return newSimpleClient(api, cfg.RNG.MaxProcessingTime), nil
+}
 
-	return client, nil
+func newSimpleClient(api RNGClient, maxProcessingTime time.Duration) *SimpleClient {
+	return &SimpleClient{
+		api:               api,
+		MaxProcessingTime: maxProcessingTime,
+	}
 }
 
 func (c *SimpleClient) RandFloat() (float64, error) {
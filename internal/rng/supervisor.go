@@ -0,0 +1,270 @@
+package rng
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Health stream statuses. The supervisor pings, a backend that answers
+// StatusNotServing or does not answer in time is unhealthy.
+const (
+	StatusPing       = "PING"
+	StatusServing    = "SERVING"
+	StatusNotServing = "NOT_SERVING"
+)
+
+const (
+	defaultHealthInterval = 5 * time.Second
+	defaultHealthTimeout  = 5 * time.Second
+	defaultMaxBackoff     = time.Minute
+	minBackoff            = 500 * time.Millisecond
+)
+
+var ErrNoBackend = errors.New("no RNG backend available")
+
+// backend is an RNG server the supervisor can draw from
+type backend struct {
+	addr    string
+	api     RNGClient
+	client  Client
+	healthy atomic.Bool
+}
+
+func newBackend(addr string, api RNGClient, client Client) *backend {
+	b := &backend{addr: addr, api: api, client: client}
+	b.healthy.Store(true)
+
+	return b
+}
+
+// Supervisor keeps the HealthCheck stream of every RNG backend open and
+// serves requests from the first healthy backend in order, failing over to
+// the next one when a backend is unhealthy or a request fails. Broken
+// streams are reopened with exponential backoff.
+type Supervisor struct {
+	backends []*backend
+
+	interval   time.Duration
+	timeout    time.Duration
+	maxBackoff time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newSupervisor(backends []*backend, opts Options) *Supervisor {
+	s := &Supervisor{
+		backends:   backends,
+		interval:   opts.HealthInterval,
+		timeout:    opts.HealthTimeout,
+		maxBackoff: opts.MaxBackoff,
+	}
+
+	if s.interval <= 0 {
+		s.interval = defaultHealthInterval
+	}
+	if s.timeout <= 0 {
+		s.timeout = defaultHealthTimeout
+	}
+	if s.maxBackoff <= 0 {
+		s.maxBackoff = defaultMaxBackoff
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	for _, b := range backends {
+		s.wg.Add(1)
+		go s.watch(ctx, b)
+	}
+
+	return s
+}
+
+// Close stops watching the backends
+func (s *Supervisor) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// Healthy reports the health of every backend by address
+func (s *Supervisor) Healthy() map[string]bool {
+	health := make(map[string]bool, len(s.backends))
+	for _, b := range s.backends {
+		health[b.addr] = b.healthy.Load()
+	}
+
+	return health
+}
+
+// watch keeps a health stream to the backend open until ctx is done
+func (s *Supervisor) watch(ctx context.Context, b *backend) {
+	defer s.wg.Done()
+
+	backoff := minBackoff
+
+	for {
+		err := s.check(ctx, b, func() { backoff = minBackoff })
+		if ctx.Err() != nil {
+			return
+		}
+
+		s.setHealthy(b, false, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+
+		backoff = min(backoff*2, s.maxBackoff)
+	}
+}
+
+// check pings the backend over one health stream until it fails.
+// healthy is called after every answered ping.
+func (s *Supervisor) check(ctx context.Context, b *backend, healthy func()) error {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := b.api.HealthCheck(streamCtx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		if err := stream.Send(&Status{Status: StatusPing}); err != nil {
+			return err
+		}
+
+		// A backend that stops answering is unhealthy, cancel the stream to unblock Recv
+		timer := time.AfterFunc(s.timeout, cancel)
+		status, err := stream.Recv()
+		timer.Stop()
+
+		if err != nil {
+			return err
+		}
+
+		if status.GetStatus() == StatusNotServing {
+			return fmt.Errorf("backend reports %s", StatusNotServing)
+		}
+
+		s.setHealthy(b, true, nil)
+		healthy()
+
+		select {
+		case <-time.After(s.interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// probe pings the backend once over a new health stream, an RNG server
+// that is down fails it within timeout
+func probe(api RNGClient, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	stream, err := api.HealthCheck(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := stream.Send(&Status{Status: StatusPing}); err != nil {
+		return err
+	}
+
+	status, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	if status.GetStatus() == StatusNotServing {
+		return fmt.Errorf("backend reports %s", StatusNotServing)
+	}
+
+	return nil
+}
+
+func (s *Supervisor) setHealthy(b *backend, healthy bool, err error) {
+	if b.healthy.Swap(healthy) == healthy {
+		return
+	}
+
+	if healthy {
+		log.Printf("RNG backend %s is healthy", b.addr)
+	} else {
+		log.Printf("RNG backend %s is unhealthy: %v", b.addr, err)
+	}
+}
+
+// do runs call on the healthy backends in order, then on the unhealthy ones,
+// until it succeeds
+func (s *Supervisor) do(call func(Client) error) error {
+	var errs []error
+
+	for _, healthy := range []bool{true, false} {
+		for _, b := range s.backends {
+			if b.healthy.Load() != healthy {
+				continue
+			}
+
+			err := call(b.client)
+			if err == nil {
+				return nil
+			}
+
+			s.setHealthy(b, false, err)
+			errs = append(errs, fmt.Errorf("%s: %w", b.addr, err))
+		}
+	}
+
+	if len(errs) == 0 {
+		return ErrNoBackend
+	}
+
+	return fmt.Errorf("%w: %w", ErrNoBackend, errors.Join(errs...))
+}
+
+func (s *Supervisor) Rand(max uint64) (rand uint64, err error) {
+	err = s.do(func(c Client) (err error) {
+		rand, err = c.Rand(max)
+		return err
+	})
+
+	return rand, err
+}
+
+func (s *Supervisor) RandSlice(maxSlice []uint64) (rand []uint64, err error) {
+	err = s.do(func(c Client) (err error) {
+		rand, err = c.RandSlice(maxSlice)
+		return err
+	})
+
+	return rand, err
+}
+
+func (s *Supervisor) RandFloat() (rand float64, err error) {
+	err = s.do(func(c Client) (err error) {
+		rand, err = c.RandFloat()
+		return err
+	})
+
+	return rand, err
+}
+
+func (s *Supervisor) RandFloatSlice(count int) (rand []float64, err error) {
+	err = s.do(func(c Client) (err error) {
+		rand, err = c.RandFloatSlice(count)
+		return err
+	})
+
+	return rand, err
+}
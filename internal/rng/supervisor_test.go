@@ -0,0 +1,200 @@
+package rng
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"piggy-bank/config"
+)
+
+// switchAPI is an RNG server that can be taken down, it then fails requests
+// and health checks
+type switchAPI struct {
+	countingAPI
+	down chan bool
+}
+
+func newSwitchAPI() *switchAPI {
+	a := &switchAPI{down: make(chan bool, 1)}
+	a.down <- false
+
+	return a
+}
+
+func (a *switchAPI) isDown() bool {
+	down := <-a.down
+	a.down <- down
+
+	return down
+}
+
+func (a *switchAPI) setDown(down bool) {
+	<-a.down
+	a.down <- down
+}
+
+func (a *switchAPI) Rand(ctx context.Context, in *RandRequest, opts ...grpc.CallOption) (*RandResponse, error) {
+	if a.isDown() {
+		return nil, errors.New("unavailable")
+	}
+
+	return a.countingAPI.Rand(ctx, in, opts...)
+}
+
+func (a *switchAPI) HealthCheck(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Status, Status], error) {
+	if a.isDown() {
+		return nil, errors.New("unavailable")
+	}
+
+	return &healthStream{api: a, ctx: ctx}, nil
+}
+
+type healthStream struct {
+	grpc.ClientStream
+	api *switchAPI
+	ctx context.Context
+}
+
+func (s *healthStream) Send(*Status) error {
+	return s.ctx.Err()
+}
+
+func (s *healthStream) Recv() (*Status, error) {
+	if s.api.isDown() {
+		return &Status{Status: StatusNotServing}, nil
+	}
+
+	return &Status{Status: StatusServing}, nil
+}
+
+func waitHealthy(t *testing.T, s *Supervisor, addr string, want bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Healthy()[addr] != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s healthy = %v, want %v", addr, !want, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSupervisorFailover(t *testing.T) {
+	primary, secondary := newSwitchAPI(), newSwitchAPI()
+
+	s := newSupervisor([]*backend{
+		newBackend("primary", primary, newSimpleClient(primary, time.Second)),
+		newBackend("secondary", secondary, newSimpleClient(secondary, time.Second)),
+	}, Options{HealthInterval: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+	defer s.Close()
+
+	if _, err := s.Rand(10); err != nil {
+		t.Fatalf("Rand() error = %v", err)
+	}
+	if primary.calls.Load() != 1 || secondary.calls.Load() != 0 {
+		t.Fatalf("calls = %d/%d, want the primary to serve", primary.calls.Load(), secondary.calls.Load())
+	}
+
+	// The failed request marks the primary unhealthy and is served by the secondary
+	primary.setDown(true)
+	if _, err := s.Rand(10); err != nil {
+		t.Fatalf("Rand() error = %v", err)
+	}
+	if s.Healthy()["primary"] {
+		t.Error("primary is healthy after a failed request")
+	}
+	if secondary.calls.Load() != 1 {
+		t.Errorf("secondary calls = %d, want 1", secondary.calls.Load())
+	}
+
+	// Once the health stream is reopened the primary serves again
+	primary.setDown(false)
+	waitHealthy(t, s, "primary", true)
+
+	if _, err := s.Rand(10); err != nil {
+		t.Fatalf("Rand() error = %v", err)
+	}
+	if primary.calls.Load() != 2 {
+		t.Errorf("primary calls = %d, want 2", primary.calls.Load())
+	}
+
+	primary.setDown(true)
+	secondary.setDown(true)
+	waitHealthy(t, s, "secondary", false)
+
+	if _, err := s.Rand(10); !errors.Is(err, ErrNoBackend) {
+		t.Errorf("Rand() error = %v, want ErrNoBackend", err)
+	}
+}
+
+// listen serves srv on a local TCP port and returns its address
+func listen(t *testing.T, srv *Server) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+
+	server := srv.GRPCServer()
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	return lis.Addr().String()
+}
+
+// unreachable returns the address of a closed local TCP port
+func unreachable(t *testing.T) (host, port string) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	lis.Close()
+
+	host, port, _ = net.SplitHostPort(lis.Addr().String())
+
+	return host, port
+}
+
+func TestServiceUnreachablePrimary(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.RNG.Host, cfg.RNG.Port = unreachable(t)
+	cfg.RNG.MaxProcessingTime = time.Second
+	primary := cfg.RNG.Host + ":" + cfg.RNG.Port
+
+	if _, err := NewServiceWithOptions(cfg, Options{Strict: true}); !errors.Is(err, ErrNoBackend) {
+		t.Errorf("NewServiceWithOptions() strict error = %v, want ErrNoBackend", err)
+	}
+
+	// Without a secondary the service falls back to the mock client
+	service, err := NewServiceWithOptions(cfg, Options{})
+	if err != nil {
+		t.Fatalf("NewServiceWithOptions() error = %v", err)
+	}
+	if _, ok := service.GetClient().(*MockClient); !ok {
+		t.Errorf("client = %T, want the *MockClient fallback", service.GetClient())
+	}
+
+	secondary := listen(t, NewServer())
+
+	service, err = NewServiceWithOptions(cfg, Options{SecondaryAddr: secondary, Strict: true})
+	if err != nil {
+		t.Fatalf("NewServiceWithOptions() with a secondary error = %v", err)
+	}
+	defer service.Close()
+
+	if health := service.Healthy(); health[primary] || !health[secondary] {
+		t.Errorf("Healthy() = %v, want only the secondary healthy", health)
+	}
+
+	if _, err := service.GetClient().Rand(10); err != nil {
+		t.Errorf("Rand() error = %v", err)
+	}
+}
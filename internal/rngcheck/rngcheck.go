@@ -0,0 +1,148 @@
+// Package rngcheck runs statistical tests on the values of an RNG client, the
+// pre-check of uniformity and independence a certification lab performs.
+package rngcheck
+
+import (
+	"fmt"
+
+	"piggy-bank/internal/rng"
+)
+
+// DefaultMaxes are the ranges the engine draws from
+var DefaultMaxes = []uint64{81, 93, 96, 100}
+
+// DefaultAlpha is the significance level below which a test fails
+const DefaultAlpha = 0.01
+
+// batchSize is the number of values requested from the client at once
+const batchSize = 1000
+
+// Result is the outcome of one statistical test
+type Result struct {
+	Test      string  `json:"test"`
+	Max       uint64  `json:"max,omitempty"` // zero for RandFloat
+	Samples   int     `json:"samples"`
+	Statistic float64 `json:"statistic"`
+	PValue    float64 `json:"p_value"`
+	Passed    bool    `json:"passed"`
+}
+
+func (r Result) String() string {
+	verdict := "FAIL"
+	if r.Passed {
+		verdict = "PASS"
+	}
+
+	source := "RandFloat"
+	if r.Max > 0 {
+		source = fmt.Sprintf("Rand(%d)", r.Max)
+	}
+
+	return fmt.Sprintf("%-4s %-20s %-10s n=%d statistic=%.4f p=%.4f", verdict, r.Test, source, r.Samples, r.Statistic, r.PValue)
+}
+
+// Run draws samples values for every max and runs the chi-square, runs and
+// serial correlation tests on them, then the Kolmogorov-Smirnov test on
+// samples floats. A test passes when its p-value is at least alpha.
+func Run(client rng.Client, samples int, maxes []uint64, alpha float64) ([]Result, error) {
+	if samples < 2 {
+		return nil, fmt.Errorf("at least 2 samples are needed, got %d", samples)
+	}
+
+	var results []Result
+
+	for _, max := range maxes {
+		if max < 2 {
+			return nil, fmt.Errorf("max must be at least 2, got %d", max)
+		}
+
+		values, err := draw(client, max, samples)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, result := range []Result{
+			chiSquare(values, max),
+			runs(values, max),
+			serialCorrelation(values),
+		} {
+			result.Max = max
+			result.Samples = samples
+			result.Passed = result.PValue >= alpha
+			results = append(results, result)
+		}
+	}
+
+	floats, err := drawFloats(client, samples)
+	if err != nil {
+		return nil, err
+	}
+
+	result := kolmogorovSmirnov(floats)
+	result.Samples = samples
+	result.Passed = result.PValue >= alpha
+
+	return append(results, result), nil
+}
+
+// Passed reports whether every test passed
+func Passed(results []Result) bool {
+	for _, result := range results {
+		if !result.Passed {
+			return false
+		}
+	}
+
+	return true
+}
+
+func draw(client rng.Client, max uint64, samples int) ([]uint64, error) {
+	values := make([]uint64, 0, samples)
+
+	for len(values) < samples {
+		batch, err := client.RandSlice(sliceOf(max, min(batchSize, samples-len(values))))
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw values below %d: %w", max, err)
+		}
+
+		for _, value := range batch {
+			if value >= max {
+				return nil, fmt.Errorf("RNG returned %d, not below %d", value, max)
+			}
+		}
+
+		values = append(values, batch...)
+	}
+
+	return values, nil
+}
+
+func drawFloats(client rng.Client, samples int) ([]float64, error) {
+	values := make([]float64, 0, samples)
+
+	for len(values) < samples {
+		batch, err := client.RandFloatSlice(min(batchSize, samples-len(values)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw floats: %w", err)
+		}
+
+		for _, value := range batch {
+			if value < 0 || value >= 1 {
+				return nil, fmt.Errorf("RNG returned float %v, not in [0, 1)", value)
+			}
+		}
+
+		values = append(values, batch...)
+	}
+
+	return values, nil
+}
+
+func sliceOf(value uint64, size int) []uint64 {
+	values := make([]uint64, size)
+	for i := range values {
+		values[i] = value
+	}
+
+	return values
+}
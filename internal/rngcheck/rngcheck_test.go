@@ -0,0 +1,105 @@
+package rngcheck
+
+import (
+	"math"
+	"testing"
+
+	"piggy-bank/internal/rng"
+)
+
+// cyclicClient counts up through the range, perfectly uniform but not random
+type cyclicClient struct {
+	rng.Client
+	next uint64
+}
+
+func (c *cyclicClient) RandSlice(maxSlice []uint64) ([]uint64, error) {
+	values := make([]uint64, len(maxSlice))
+	for i, max := range maxSlice {
+		values[i] = c.next % max
+		c.next++
+	}
+
+	return values, nil
+}
+
+// biasedClient draws the low half of the range twice as often
+type biasedClient struct {
+	rng.Client
+}
+
+func (c *biasedClient) RandSlice(maxSlice []uint64) ([]uint64, error) {
+	values, err := c.Client.RandSlice(maxSlice)
+	for i, max := range maxSlice {
+		if values[i] >= max/2 && values[i]%3 == 0 {
+			values[i] -= max / 2
+		}
+	}
+
+	return values, err
+}
+
+func results(t *testing.T, client rng.Client) map[string]Result {
+	t.Helper()
+
+	res, err := Run(client, 20_000, []uint64{96}, DefaultAlpha)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	byTest := make(map[string]Result)
+	for _, result := range res {
+		byTest[result.Test] = result
+	}
+
+	return byTest
+}
+
+func TestRunSeeded(t *testing.T) {
+	res, err := Run(rng.NewSeededClient(42), 20_000, DefaultMaxes, DefaultAlpha)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(res) != 3*len(DefaultMaxes)+1 {
+		t.Errorf("Run() returned %d results, want %d", len(res), 3*len(DefaultMaxes)+1)
+	}
+
+	for _, result := range res {
+		if !result.Passed {
+			t.Errorf("%v", result)
+		}
+	}
+}
+
+func TestRunDetectsFlaws(t *testing.T) {
+	biased := results(t, &biasedClient{Client: rng.NewSeededClient(42)})
+	if biased["chi-square"].Passed {
+		t.Errorf("biased client passed: %v", biased["chi-square"])
+	}
+
+	cyclic := results(t, &cyclicClient{Client: rng.NewSeededClient(42)})
+	if !cyclic["chi-square"].Passed {
+		t.Errorf("uniform cyclic client failed: %v", cyclic["chi-square"])
+	}
+	if cyclic["runs"].Passed {
+		t.Errorf("cyclic client passed: %v", cyclic["runs"])
+	}
+	if cyclic["serial correlation"].Passed {
+		t.Errorf("cyclic client passed: %v", cyclic["serial correlation"])
+	}
+}
+
+func TestGammaQ(t *testing.T) {
+	// Q(1, x) = exp(-x), on both sides of the series and continued fraction split
+	for _, x := range []float64{0.1, 1, 1.9, 2.1, 5, 30} {
+		if got, want := gammaQ(1, x), math.Exp(-x); math.Abs(got-want) > 1e-10 {
+			t.Errorf("gammaQ(1, %v) = %v, want %v", x, got, want)
+		}
+	}
+
+	// 99th percentile of the chi-square distribution with 95 degrees of freedom
+	if got := gammaQ(95.0/2, 129.973/2); math.Abs(got-0.01) > 1e-4 {
+		t.Errorf("chi-square p-value = %v, want 0.01", got)
+	}
+}
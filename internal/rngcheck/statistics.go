@@ -0,0 +1,215 @@
+package rngcheck
+
+import (
+	"math"
+	"slices"
+)
+
+const (
+	epsilon = 1e-14
+	tiny    = 1e-300
+)
+
+// chiSquare tests that every value below max is drawn equally often
+func chiSquare(values []uint64, max uint64) Result {
+	counts := make([]int64, max)
+	for _, value := range values {
+		counts[value]++
+	}
+
+	expected := float64(len(values)) / float64(max)
+
+	var statistic float64
+	for _, count := range counts {
+		diff := float64(count) - expected
+		statistic += diff * diff / expected
+	}
+
+	degrees := float64(max - 1)
+
+	return Result{
+		Test:      "chi-square",
+		Statistic: statistic,
+		PValue:    gammaQ(degrees/2, statistic/2),
+	}
+}
+
+// runs is the Wald-Wolfowitz test of the runs of values above and below the
+// middle of the range, values in the middle of an odd range are skipped
+func runs(values []uint64, max uint64) Result {
+	middle := float64(max-1) / 2
+
+	var (
+		above, below, count int
+		last                int
+	)
+
+	for _, value := range values {
+		side := 0
+		switch {
+		case float64(value) > middle:
+			side = 1
+			above++
+		case float64(value) < middle:
+			side = -1
+			below++
+		default:
+			continue
+		}
+
+		if side != last {
+			count++
+			last = side
+		}
+	}
+
+	n := float64(above + below)
+	n1, n2 := float64(above), float64(below)
+
+	mean := 2*n1*n2/n + 1
+	variance := 2 * n1 * n2 * (2*n1*n2 - n) / (n * n * (n - 1))
+	if variance <= 0 {
+		return Result{Test: "runs", Statistic: math.Inf(1), PValue: 0}
+	}
+
+	z := (float64(count) - mean) / math.Sqrt(variance)
+
+	return Result{
+		Test:      "runs",
+		Statistic: z,
+		PValue:    twoSided(z),
+	}
+}
+
+// serialCorrelation tests that consecutive values are uncorrelated
+func serialCorrelation(values []uint64) Result {
+	var mean float64
+	for _, value := range values {
+		mean += float64(value)
+	}
+	mean /= float64(len(values))
+
+	var covariance, variance float64
+	for i, value := range values {
+		diff := float64(value) - mean
+		variance += diff * diff
+
+		if i+1 < len(values) {
+			covariance += diff * (float64(values[i+1]) - mean)
+		}
+	}
+
+	if variance == 0 {
+		return Result{Test: "serial correlation", Statistic: 1, PValue: 0}
+	}
+
+	correlation := covariance / variance
+
+	return Result{
+		Test:      "serial correlation",
+		Statistic: correlation,
+		PValue:    twoSided(correlation * math.Sqrt(float64(len(values)))),
+	}
+}
+
+// kolmogorovSmirnov tests that floats are uniform on [0, 1)
+func kolmogorovSmirnov(values []float64) Result {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	n := float64(len(sorted))
+
+	var distance float64
+	for i, value := range sorted {
+		distance = max(distance, float64(i+1)/n-value, value-float64(i)/n)
+	}
+
+	sqrtN := math.Sqrt(n)
+
+	return Result{
+		Test:      "kolmogorov-smirnov",
+		Statistic: distance,
+		PValue:    kolmogorovQ((sqrtN + 0.12 + 0.11/sqrtN) * distance),
+	}
+}
+
+// twoSided is the two-sided p-value of a standard normal z
+func twoSided(z float64) float64 {
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// kolmogorovQ is the complementary Kolmogorov distribution function
+func kolmogorovQ(lambda float64) float64 {
+	sign, sum, previous := 2.0, 0.0, 0.0
+
+	for j := 1; j <= 100; j++ {
+		term := sign * math.Exp(-2*float64(j*j)*lambda*lambda)
+		sum += term
+
+		if math.Abs(term) <= 1e-3*previous || math.Abs(term) <= 1e-8*sum {
+			return min(max(sum, 0), 1)
+		}
+
+		sign = -sign
+		previous = math.Abs(term)
+	}
+
+	// The series does not converge for small lambda, where the distribution is near 1
+	return 1
+}
+
+// gammaQ is the regularized upper incomplete gamma function Q(a, x), the
+// survival function of the chi-square distribution with 2a degrees of freedom at 2x
+func gammaQ(a, x float64) float64 {
+	if x <= 0 {
+		return 1
+	}
+
+	lgamma, _ := math.Lgamma(a)
+	prefix := math.Exp(-x + a*math.Log(x) - lgamma)
+
+	if x < a+1 {
+		// Series of the lower function P(a, x)
+		term := 1 / a
+		sum := term
+		for n := 1; n < 10_000; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*epsilon {
+				break
+			}
+		}
+
+		return 1 - sum*prefix
+	}
+
+	// Continued fraction of Q(a, x) by the modified Lentz method
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < 10_000; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+
+		d = 1 / d
+		delta := d * c
+		h *= delta
+
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+
+	return prefix * h
+}
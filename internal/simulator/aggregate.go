@@ -0,0 +1,236 @@
+package simulator
+
+import (
+	"math/big"
+	"math/bits"
+
+	"piggy-bank/internal/engine"
+)
+
+// wideSum is a running sum of non-negative values kept in a uint64. Before
+// the uint64 would wrap, the total is promoted to a big.Int.
+type wideSum struct {
+	value    uint64
+	overflow *big.Int
+}
+
+func (s *wideSum) add(v int64) {
+	sum, carry := bits.Add64(s.value, uint64(v), 0)
+	if carry != 0 {
+		s.promote(s.value)
+		sum = uint64(v)
+	}
+
+	s.value = sum
+}
+
+func (s *wideSum) addSquare(v int64) {
+	hi, lo := bits.Mul64(uint64(v), uint64(v))
+	if hi != 0 {
+		square := new(big.Int).SetUint64(uint64(v))
+		s.promoteBig(square.Mul(square, square))
+		return
+	}
+
+	sum, carry := bits.Add64(s.value, lo, 0)
+	if carry != 0 {
+		s.promote(s.value)
+		sum = lo
+	}
+
+	s.value = sum
+}
+
+func (s *wideSum) promote(v uint64) {
+	s.promoteBig(new(big.Int).SetUint64(v))
+}
+
+func (s *wideSum) promoteBig(v *big.Int) {
+	if s.overflow == nil {
+		s.overflow = new(big.Int)
+	}
+
+	s.overflow.Add(s.overflow, v)
+}
+
+// addTo adds the sum to total
+func (s *wideSum) addTo(total *big.Int) {
+	total.Add(total, new(big.Int).SetUint64(s.value))
+
+	if s.overflow != nil {
+		total.Add(total, s.overflow)
+	}
+}
+
+// partialResult accumulates the spins of one chunk in a worker without
+// allocating, it is merged into the SimulationResult by the collector
+type partialResult struct {
+	count          int64
+	maxExposure    int64
+	baseAwardCount int64
+	x1Count        int64
+	x10Count       int64
+	x100Count      int64
+
+	spent              wideSum
+	baseAward          wideSum
+	award              wideSum
+	baseAwardSquareSum wideSum
+	awardSquareSum     wideSum
+
+	reelsets []partialReelset
+	buckets  []partialBucket
+	awards   map[int64]int64
+
+	histogram *Histogram
+}
+
+type partialReelset struct {
+	count      int64
+	baseCount  int64
+	awardCount int64
+	award      wideSum
+	symbols    map[SymbolKey]*partialSymbol
+}
+
+type partialSymbol struct {
+	hits  int64
+	award wideSum
+}
+
+type partialBucket struct {
+	count int64
+	award wideSum
+}
+
+func newPartialResult(reelsets int, histogram *Histogram) *partialResult {
+	p := &partialResult{
+		reelsets:  make([]partialReelset, reelsets),
+		buckets:   make([]partialBucket, len(histogram.Buckets)),
+		awards:    make(map[int64]int64),
+		histogram: histogram,
+	}
+
+	for i := range p.reelsets {
+		p.reelsets[i].symbols = make(map[SymbolKey]*partialSymbol)
+	}
+
+	return p
+}
+
+// add accumulates a simulated spin
+func (p *partialResult) add(spin *engine.Spin) {
+	wager := spin.Wager
+	award := spin.Award
+	baseAward := spin.BaseAward()
+
+	p.count++
+
+	p.spent.add(wager)
+	p.baseAward.add(baseAward)
+	p.award.add(award)
+	p.baseAwardSquareSum.addSquare(baseAward)
+	p.awardSquareSum.addSquare(award)
+
+	p.maxExposure = max(p.maxExposure, award)
+
+	if award > 0 {
+		p.baseAwardCount++
+		p.awards[award]++
+	}
+
+	if award >= wager*1 {
+		p.x1Count++
+	}
+
+	if award >= wager*10 {
+		p.x10Count++
+	}
+
+	if award >= wager*100 {
+		p.x100Count++
+	}
+
+	bucket := &p.buckets[p.histogram.bucketIndex(award, wager)]
+	bucket.count++
+	bucket.award.add(award)
+
+	p.reelsets[spin.Reelset].baseCount++
+	p.reelsets[spin.Reelset].add(baseAward, spin.LineWins)
+
+	for _, freeSpin := range spin.FreeSpins {
+		p.reelsets[freeSpin.Reelset].add(freeSpin.Award, freeSpin.LineWins)
+	}
+}
+
+func (r *partialReelset) add(award int64, lineWins []engine.LineWin) {
+	r.count++
+
+	if award > 0 {
+		r.awardCount++
+		r.award.add(award)
+	}
+
+	for _, lineWin := range lineWins {
+		key := SymbolKey{Symbol: lineWin.Symbol, Length: lineWin.Count}
+
+		symbol, ok := r.symbols[key]
+		if !ok {
+			symbol = &partialSymbol{}
+			r.symbols[key] = symbol
+		}
+
+		symbol.hits++
+		symbol.award.add(lineWin.Award)
+	}
+}
+
+// merge adds the spins accumulated by a worker
+func (r *SimulationResult) merge(p *partialResult) {
+	r.Count += p.count
+
+	p.spent.addTo(r.Spent)
+	p.baseAward.addTo(r.BaseAward)
+	p.award.addTo(r.Award)
+	p.baseAwardSquareSum.addTo(r.BaseAwardSquareSum)
+	p.awardSquareSum.addTo(r.AwardSquareSum)
+
+	r.MaxExposure = max(r.MaxExposure, p.maxExposure)
+
+	r.BaseAwardCount += p.baseAwardCount
+	r.X1Count += p.x1Count
+	r.X10Count += p.x10Count
+	r.X100Count += p.x100Count
+
+	for i := range p.reelsets {
+		r.Reelsets[i].merge(&p.reelsets[i])
+	}
+
+	for i := range p.buckets {
+		bucket := r.Histogram.Buckets[i]
+		bucket.Count += p.buckets[i].count
+		p.buckets[i].award.addTo(bucket.Award)
+	}
+
+	for award, count := range p.awards {
+		r.Histogram.awards[award] += count
+	}
+}
+
+func (r *ReelsetResult) merge(p *partialReelset) {
+	r.Count += p.count
+	r.BaseCount += p.baseCount
+	r.AwardCount += p.awardCount
+	p.award.addTo(r.Award)
+
+	for key, symbol := range p.symbols {
+		merged, ok := r.Symbols[key]
+		if !ok {
+			merged = &SymbolResult{Symbol: key.Symbol, Length: key.Length, Award: new(big.Int)}
+			r.Symbols[key] = merged
+		}
+
+		merged.Hits += symbol.hits
+		symbol.award.addTo(merged.Award)
+	}
+}
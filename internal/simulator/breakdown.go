@@ -0,0 +1,132 @@
+package simulator
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"piggy-bank/internal/engine"
+)
+
+// ReelsetResult accumulates the spins played on one reelset, free spins included
+type ReelsetResult struct {
+	Name        string   `xlsx:"Reelset"`
+	ExpectedRTP float64  `xlsx:"Expected RTP"`
+	Count       int64    `xlsx:"Count"`
+	BaseCount   int64    `xlsx:"Base Count"`
+	AwardCount  int64    `xlsx:"Award Count"`
+	Award       *big.Int `xlsx:"Award"`
+	RTP         float64  `xlsx:"RTP"`
+
+	Symbols map[SymbolKey]*SymbolResult `xlsx:"-"`
+}
+
+// SymbolKey identifies a line win by its symbol and number of symbols in a row
+type SymbolKey struct {
+	Symbol engine.Symbol
+	Length int
+}
+
+// SymbolResult accumulates line wins of one symbol and length on a reelset
+type SymbolResult struct {
+	Symbol engine.Symbol `xlsx:"Symbol"`
+	Length int           `xlsx:"Length"`
+	Hits   int64         `xlsx:"Hits"`
+	Award  *big.Int      `xlsx:"Award"`
+	RTP    float64       `xlsx:"RTP"`
+}
+
+type ReelsetView struct {
+	Name        string       `json:"name" xlsx:"Reelset"`
+	Count       string       `json:"count" xlsx:"Count"`
+	Rate        string       `json:"rate" xlsx:"Rate"`
+	BaseCount   string       `json:"base_count" xlsx:"Base Count"`
+	AwardRate   string       `json:"award_rate" xlsx:"Award Rate (Hit Rate)"`
+	Award       string       `json:"award" xlsx:"Award"`
+	RTP         string       `json:"rtp" xlsx:"RTP"`
+	ExpectedRTP string       `json:"expected_rtp" xlsx:"Expected RTP"`
+	Symbols     []SymbolView `json:"symbols" xlsx:"-"`
+}
+
+type SymbolView struct {
+	Reelset string `json:"-" xlsx:"Reelset"`
+	Symbol  string `json:"symbol" xlsx:"Symbol"`
+	Length  int    `json:"length" xlsx:"Length"`
+	Hits    string `json:"hits" xlsx:"Hits"`
+	HitRate string `json:"hit_rate" xlsx:"Hit Rate"`
+	Award   string `json:"award" xlsx:"Award"`
+	RTP     string `json:"rtp" xlsx:"RTP"`
+}
+
+func newReelsetResults(game *engine.Game) []*ReelsetResult {
+	results := make([]*ReelsetResult, len(game.ReelsetData))
+	for i, data := range game.ReelsetData {
+		results[i] = &ReelsetResult{
+			Name:        data.Name,
+			ExpectedRTP: data.RTP,
+			Award:       new(big.Int),
+			Symbols:     make(map[SymbolKey]*SymbolResult),
+		}
+	}
+
+	return results
+}
+
+// calculateRTP computes the RTP of every reelset and symbol from spins played on the reelset
+func (r *ReelsetResult) calculateRTP(wager int64) {
+	if r.Count == 0 {
+		return
+	}
+
+	spent := new(big.Float).SetInt64(r.Count * wager)
+
+	r.RTP, _ = new(big.Float).Quo(new(big.Float).SetInt(r.Award), spent).Float64()
+
+	for _, symbol := range r.Symbols {
+		symbol.RTP, _ = new(big.Float).Quo(new(big.Float).SetInt(symbol.Award), spent).Float64()
+	}
+}
+
+// SortedSymbols returns the symbol results ordered by symbol and length
+func (r *ReelsetResult) SortedSymbols() []*SymbolResult {
+	symbols := make([]*SymbolResult, 0, len(r.Symbols))
+	for _, symbol := range r.Symbols {
+		symbols = append(symbols, symbol)
+	}
+
+	sort.Slice(symbols, func(i, j int) bool {
+		if symbols[i].Symbol != symbols[j].Symbol {
+			return symbols[i].Symbol < symbols[j].Symbol
+		}
+		return symbols[i].Length < symbols[j].Length
+	})
+
+	return symbols
+}
+
+func (r *ReelsetResult) View(totalCount int64) ReelsetView {
+	view := ReelsetView{
+		Name:        r.Name,
+		Count:       fmt.Sprint(r.Count),
+		Rate:        countToRate(r.Count, totalCount),
+		BaseCount:   fmt.Sprint(r.BaseCount),
+		AwardRate:   countToRate(r.AwardCount, r.Count),
+		Award:       r.Award.String(),
+		RTP:         floatWithPrecision(r.RTP),
+		ExpectedRTP: floatWithPrecision(r.ExpectedRTP),
+	}
+
+	for _, symbol := range r.SortedSymbols() {
+		view.Symbols = append(view.Symbols, SymbolView{
+			Reelset: r.Name,
+			Symbol:  symbol.Symbol.String(),
+			Length:  symbol.Length,
+			Hits:    fmt.Sprint(symbol.Hits),
+			HitRate: countToRate(symbol.Hits, r.Count),
+			Award:   symbol.Award.String(),
+			RTP:     floatWithPrecision(symbol.RTP),
+		})
+	}
+
+	return view
+}
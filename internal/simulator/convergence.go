@@ -0,0 +1,116 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Normal quantiles of the two sided 95% and 99% confidence intervals
+const (
+	z95 = 1.959963984540054
+	z99 = 2.5758293035489004
+)
+
+// statsPrecision keeps the variance exact enough when subtracting the large sums
+const statsPrecision = 256
+
+// ConfidenceInterval bounds the RTP, as a fraction of the wager, for a confidence level
+type ConfidenceInterval struct {
+	Level float64
+	Lower float64
+	Upper float64
+}
+
+// Checkpoint is the RTP after a number of simulated spins
+type Checkpoint struct {
+	Spins        int64
+	RTP          float64
+	Confidence95 ConfidenceInterval
+}
+
+type ConfidenceIntervalView struct {
+	Level string `json:"level" xlsx:"Level"`
+	Lower string `json:"lower" xlsx:"Lower"`
+	Upper string `json:"upper" xlsx:"Upper"`
+	Width string `json:"width" xlsx:"Width"`
+}
+
+type CheckpointView struct {
+	Spins string `json:"spins" xlsx:"Spins"`
+	RTP   string `json:"rtp" xlsx:"RTP"`
+	Lower string `json:"lower_95" xlsx:"Lower 95%"`
+	Upper string `json:"upper_95" xlsx:"Upper 95%"`
+}
+
+func (c ConfidenceInterval) Width() float64 {
+	return c.Upper - c.Lower
+}
+
+func (c ConfidenceInterval) View() ConfidenceIntervalView {
+	return ConfidenceIntervalView{
+		Level: floatWithPrecision(c.Level) + "%",
+		Lower: floatWithPrecision(c.Lower),
+		Upper: floatWithPrecision(c.Upper),
+		Width: floatWithPrecision(c.Width()),
+	}
+}
+
+func newCheckpoint(spins int64, award, squareSum *big.Int, wager int64) Checkpoint {
+	rtp, _ := new(big.Float).Quo(new(big.Float).SetInt(award), big.NewFloat(float64(spins*wager))).Float64()
+
+	return Checkpoint{
+		Spins:        spins,
+		RTP:          rtp,
+		Confidence95: rtpConfidenceInterval(award, squareSum, spins, wager, z95),
+	}
+}
+
+// rtpConfidenceInterval is the normal approximation interval of the mean award
+// per wager, computed from the award sum and the sum of squared awards
+func rtpConfidenceInterval(award, squareSum *big.Int, count, wager int64, z float64) ConfidenceInterval {
+	level := 2*normalCDF(z) - 1
+
+	if count == 0 {
+		return ConfidenceInterval{Level: level}
+	}
+
+	n := new(big.Float).SetPrec(statsPrecision).SetInt64(count)
+
+	mean := new(big.Float).SetPrec(statsPrecision).SetInt(award)
+	mean.Quo(mean, n)
+
+	variance := new(big.Float).SetPrec(statsPrecision).SetInt(squareSum)
+	variance.Quo(variance, n)
+	variance.Sub(variance, new(big.Float).SetPrec(statsPrecision).Mul(mean, mean))
+
+	meanF, _ := mean.Float64()
+	varianceF, _ := variance.Float64()
+
+	rtp := meanF / float64(wager)
+	margin := z * math.Sqrt(max(varianceF, 0)/float64(count)) / float64(wager)
+
+	return ConfidenceInterval{
+		Level: level,
+		Lower: rtp - margin,
+		Upper: rtp + margin,
+	}
+}
+
+func normalCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
+
+func checkpointsView(checkpoints []Checkpoint) []CheckpointView {
+	views := make([]CheckpointView, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		views = append(views, CheckpointView{
+			Spins: fmt.Sprint(checkpoint.Spins),
+			RTP:   floatWithPrecision(checkpoint.RTP),
+			Lower: floatWithPrecision(checkpoint.Confidence95.Lower),
+			Upper: floatWithPrecision(checkpoint.Confidence95.Upper),
+		})
+	}
+
+	return views
+}
@@ -0,0 +1,37 @@
+package simulator
+
+import (
+	"piggy-bank/internal/report"
+)
+
+// Sheets returns the summary of the simulation and its breakdowns as report sheets
+func (v *SimulationView) Sheets() ([]report.Sheet, error) {
+	var symbols []SymbolView
+	for _, reelset := range v.Reelsets {
+		symbols = append(symbols, reelset.Symbols...)
+	}
+
+	tables := []struct {
+		name  string
+		value any
+	}{
+		{"Summary", v},
+		{"Confidence", []ConfidenceIntervalView{v.RTPConfidence95, v.RTPConfidence99}},
+		{"Reelsets", v.Reelsets},
+		{"Symbols", symbols},
+		{"Histogram", v.Histogram},
+		{"Percentiles", v.Percentiles},
+		{"Checkpoints", v.Checkpoints},
+	}
+
+	sheets := make([]report.Sheet, 0, len(tables))
+	for _, table := range tables {
+		sheet, err := report.NewSheet(table.name, table.value)
+		if err != nil {
+			return nil, err
+		}
+		sheets = append(sheets, sheet)
+	}
+
+	return sheets, nil
+}
@@ -0,0 +1,172 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+)
+
+// DefaultBucketEdges are the upper bounds of the award histogram buckets in
+// multiples of the wager. Awards of zero and above the last edge get their own buckets.
+var DefaultBucketEdges = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+// DefaultPercentiles are the reported percentiles of the win size
+var DefaultPercentiles = []float64{50, 75, 90, 95, 99, 99.9, 99.99}
+
+// Histogram counts spins by award size
+type Histogram struct {
+	Edges   []float64
+	Buckets []*HistogramBucket
+
+	// awards counts winning spins by award, they are few distinct values
+	awards map[int64]int64
+}
+
+type HistogramBucket struct {
+	Label string   `xlsx:"Bucket"`
+	Count int64    `xlsx:"Count"`
+	Award *big.Int `xlsx:"Award"`
+	RTP   float64  `xlsx:"RTP"`
+}
+
+// Percentile is the smallest win that the given percent of winning spins do not exceed
+type Percentile struct {
+	Percentile float64
+	Award      int64
+}
+
+type BucketView struct {
+	Bucket string `json:"bucket" xlsx:"Bucket"`
+	Count  string `json:"count" xlsx:"Count"`
+	Rate   string `json:"rate" xlsx:"Rate"`
+	Award  string `json:"award" xlsx:"Award"`
+	RTP    string `json:"rtp" xlsx:"RTP Contribution"`
+}
+
+type PercentileView struct {
+	Percentile string  `json:"percentile" xlsx:"Percentile"`
+	Award      string  `json:"award" xlsx:"Award"`
+	Multiplier float64 `json:"multiplier" xlsx:"Multiplier"`
+}
+
+func newHistogram(edges []float64) (*Histogram, error) {
+	if len(edges) == 0 {
+		edges = DefaultBucketEdges
+	}
+
+	for i, edge := range edges {
+		if edge <= 0 || math.IsNaN(edge) || math.IsInf(edge, 0) {
+			return nil, fmt.Errorf("bucket edge %v must be a positive number", edge)
+		}
+		if i > 0 && edge <= edges[i-1] {
+			return nil, errors.New("bucket edges must be increasing")
+		}
+	}
+
+	h := &Histogram{
+		Edges:  edges,
+		awards: make(map[int64]int64),
+	}
+
+	h.Buckets = append(h.Buckets, &HistogramBucket{Label: "0", Award: new(big.Int)})
+
+	lower := "0"
+	for _, edge := range edges {
+		upper := formatMultiplier(edge)
+		h.Buckets = append(h.Buckets, &HistogramBucket{Label: fmt.Sprintf("(%s, %s]", lower, upper), Award: new(big.Int)})
+		lower = upper
+	}
+
+	h.Buckets = append(h.Buckets, &HistogramBucket{Label: fmt.Sprintf("(%s, inf)", lower), Award: new(big.Int)})
+
+	return h, nil
+}
+
+func (h *Histogram) bucketIndex(award, wager int64) int {
+	if award <= 0 {
+		return 0
+	}
+
+	multiplier := float64(award) / float64(wager)
+
+	return 1 + sort.SearchFloat64s(h.Edges, multiplier)
+}
+
+func (h *Histogram) calculateRTP(spent *big.Int) {
+	if spent.Sign() == 0 {
+		return
+	}
+
+	spentF := new(big.Float).SetInt(spent)
+	for _, bucket := range h.Buckets {
+		bucket.RTP, _ = new(big.Float).Quo(new(big.Float).SetInt(bucket.Award), spentF).Float64()
+	}
+}
+
+// Percentiles returns the win size percentiles of the winning spins
+func (h *Histogram) Percentiles(percentiles []float64) []Percentile {
+	awards := make([]int64, 0, len(h.awards))
+	total := int64(0)
+	for award, count := range h.awards {
+		awards = append(awards, award)
+		total += count
+	}
+
+	if total == 0 {
+		return nil
+	}
+
+	sort.Slice(awards, func(i, j int) bool { return awards[i] < awards[j] })
+
+	result := make([]Percentile, 0, len(percentiles))
+	for _, p := range percentiles {
+		rank := int64(math.Ceil(p / 100 * float64(total)))
+		rank = max(rank, 1)
+
+		seen := int64(0)
+		for _, award := range awards {
+			seen += h.awards[award]
+			if seen >= rank {
+				result = append(result, Percentile{Percentile: p, Award: award})
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+func (h *Histogram) View(count int64) []BucketView {
+	views := make([]BucketView, 0, len(h.Buckets))
+	for _, bucket := range h.Buckets {
+		views = append(views, BucketView{
+			Bucket: bucket.Label,
+			Count:  fmt.Sprint(bucket.Count),
+			Rate:   countToRate(bucket.Count, count),
+			Award:  bucket.Award.String(),
+			RTP:    floatWithPrecision(bucket.RTP),
+		})
+	}
+
+	return views
+}
+
+func percentilesView(percentiles []Percentile, wager int64) []PercentileView {
+	views := make([]PercentileView, 0, len(percentiles))
+	for _, p := range percentiles {
+		views = append(views, PercentileView{
+			Percentile: "P" + strconv.FormatFloat(p.Percentile, 'f', -1, 64),
+			Award:      fmt.Sprint(p.Award),
+			Multiplier: math.Round(float64(p.Award)/float64(wager)*1000) / 1000,
+		})
+	}
+
+	return views
+}
+
+func formatMultiplier(edge float64) string {
+	return strconv.FormatFloat(edge, 'f', -1, 64) + "x"
+}
@@ -0,0 +1,286 @@
+package simulator
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"piggy-bank/internal/engine"
+	"piggy-bank/internal/rng"
+)
+
+type JobStatus string
+
+const (
+	JobRunning  JobStatus = "running"
+	JobDone     JobStatus = "done"
+	JobFailed   JobStatus = "failed"
+	JobCanceled JobStatus = "canceled"
+)
+
+// Limits of the simulations the Jobs of a server run and keep
+const (
+	MaxJobSpins    = 1_000_000_000
+	MaxJobWorkers  = 64
+	MaxRunningJobs = 4
+
+	// Finished simulations are kept for JobTTL, the oldest are dropped
+	// earlier when more than MaxFinishedJobs are kept
+	MaxFinishedJobs = 100
+	JobTTL          = time.Hour
+)
+
+var (
+	ErrJobNotFound = errors.New("simulation not found")
+	ErrTooManyJobs = fmt.Errorf("at most %d simulations can run at the same time", MaxRunningJobs)
+)
+
+// JobRequest holds the parameters of a background simulation
+type JobRequest struct {
+	Spins   int64 `json:"spins"`
+	Wager   int64 `json:"wager"`
+	Workers int   `json:"workers"`
+
+	BucketEdges        []float64 `json:"buckets,omitempty"`
+	Percentiles        []float64 `json:"percentiles,omitempty"`
+	CheckpointInterval int64     `json:"checkpoint_interval,omitempty"`
+	TargetCIWidth      float64   `json:"ci_width,omitempty"`
+}
+
+// Job is a simulation running in the background
+type Job struct {
+	ID        string
+	Request   JobRequest
+	CreatedAt time.Time
+
+	done   atomic.Int64
+	cancel context.CancelFunc
+
+	mu         sync.Mutex
+	status     JobStatus
+	result     *SimulationResult
+	err        error
+	finishedAt time.Time
+}
+
+type JobView struct {
+	ID         string          `json:"id"`
+	Status     JobStatus       `json:"status"`
+	Spins      int64           `json:"spins"`
+	Wager      int64           `json:"wager"`
+	Workers    int             `json:"workers"`
+	Done       int64           `json:"done"`
+	Progress   string          `json:"progress"`
+	CreatedAt  time.Time       `json:"created_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Report     *SimulationView `json:"report,omitempty"`
+}
+
+func (j *Job) View() *JobView {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	view := &JobView{
+		ID:        j.ID,
+		Status:    j.status,
+		Spins:     j.Request.Spins,
+		Wager:     j.Request.Wager,
+		Workers:   j.Request.Workers,
+		Done:      j.done.Load(),
+		CreatedAt: j.CreatedAt,
+	}
+
+	view.Progress = countToRate(view.Done, view.Spins)
+
+	if !j.finishedAt.IsZero() {
+		finishedAt := j.finishedAt
+		view.FinishedAt = &finishedAt
+	}
+
+	if j.err != nil {
+		view.Error = j.err.Error()
+	}
+
+	if j.result != nil {
+		view.Report = j.result.View()
+	}
+
+	return view
+}
+
+func (j *Job) finish(result *SimulationResult, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.result = result
+	j.err = err
+	j.finishedAt = time.Now()
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		j.status = JobCanceled
+	case err != nil:
+		j.status = JobFailed
+	default:
+		j.status = JobDone
+	}
+}
+
+// Jobs runs simulations of a game in the background and keeps them for lookup
+type Jobs struct {
+	game       *engine.Game
+	rngService *rng.Service
+
+	maxRunning  int
+	maxFinished int
+	ttl         time.Duration
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func NewJobs(game *engine.Game, rngService *rng.Service) *Jobs {
+	return &Jobs{
+		game:        game,
+		rngService:  rngService,
+		maxRunning:  MaxRunningJobs,
+		maxFinished: MaxFinishedJobs,
+		ttl:         JobTTL,
+		jobs:        make(map[string]*Job),
+	}
+}
+
+// Start validates the request and starts the simulation in the background.
+// Workers default to the number of CPUs up to MaxJobWorkers.
+func (j *Jobs) Start(req JobRequest) (*Job, error) {
+	if req.Spins <= 0 || req.Spins > MaxJobSpins {
+		return nil, fmt.Errorf("spins must be in [1, %d], got %d", int64(MaxJobSpins), req.Spins)
+	}
+
+	if req.Wager <= 0 {
+		return nil, fmt.Errorf("wager must be positive, got %d", req.Wager)
+	}
+
+	if req.Workers < 0 || req.Workers > MaxJobWorkers {
+		return nil, fmt.Errorf("workers must be in [0, %d], got %d", MaxJobWorkers, req.Workers)
+	}
+
+	if req.Workers == 0 {
+		req.Workers = min(runtime.NumCPU(), MaxJobWorkers)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.prune(time.Now()) >= j.maxRunning {
+		return nil, ErrTooManyJobs
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	job := &Job{
+		ID:        newJobID(),
+		Request:   req,
+		CreatedAt: time.Now(),
+		cancel:    cancel,
+		status:    JobRunning,
+	}
+
+	opts := Options{
+		BucketEdges:        req.BucketEdges,
+		Percentiles:        req.Percentiles,
+		CheckpointInterval: req.CheckpointInterval,
+		TargetCIWidth:      req.TargetCIWidth,
+		Progress:           job.done.Store,
+	}
+
+	j.jobs[job.ID] = job
+
+	go func() {
+		defer cancel()
+		job.finish(Simulate(ctx, j.game, req.Spins, req.Wager, req.Workers, j.rngService, opts))
+	}()
+
+	return job, nil
+}
+
+func (j *Jobs) Get(id string) (*Job, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.prune(time.Now())
+
+	job, ok := j.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	return job, nil
+}
+
+// Cancel stops a running simulation, finished ones are left as they are
+func (j *Jobs) Cancel(id string) (*Job, error) {
+	job, err := j.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	job.cancel()
+
+	return job, nil
+}
+
+// prune drops the finished simulations kept longer than the TTL and the
+// oldest ones beyond the cap, it returns the number of running simulations
+func (j *Jobs) prune(now time.Time) int {
+	type finishedJob struct {
+		id         string
+		finishedAt time.Time
+	}
+
+	var (
+		running  int
+		finished []finishedJob
+	)
+
+	for id, job := range j.jobs {
+		job.mu.Lock()
+		status, finishedAt := job.status, job.finishedAt
+		job.mu.Unlock()
+
+		switch {
+		case status == JobRunning:
+			running++
+		case now.Sub(finishedAt) > j.ttl:
+			delete(j.jobs, id)
+		default:
+			finished = append(finished, finishedJob{id: id, finishedAt: finishedAt})
+		}
+	}
+
+	if len(finished) > j.maxFinished {
+		slices.SortFunc(finished, func(a, b finishedJob) int { return a.finishedAt.Compare(b.finishedAt) })
+		for _, job := range finished[:len(finished)-j.maxFinished] {
+			delete(j.jobs, job.id)
+		}
+	}
+
+	return running
+}
+
+func newJobID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("can not generate simulation id: %v", err))
+	}
+
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,86 @@
+package simulator
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"piggy-bank/internal/engine"
+	"piggy-bank/internal/rng"
+)
+
+func TestJobsLimits(t *testing.T) {
+	jobs := NewJobs(engine.DefaultGame(), rng.NewSeededService(42))
+	jobs.maxRunning = 2
+
+	for _, req := range []JobRequest{
+		{Spins: MaxJobSpins + 1, Wager: 100},
+		{Spins: 1000, Wager: 100, Workers: MaxJobWorkers + 1},
+	} {
+		if _, err := jobs.Start(req); err == nil {
+			t.Errorf("Start(%+v) should fail", req)
+		}
+	}
+
+	var started []*Job
+	for i := 0; i < jobs.maxRunning; i++ {
+		job, err := jobs.Start(JobRequest{Spins: MaxJobSpins, Wager: 100, Workers: 1})
+		if err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+		started = append(started, job)
+	}
+
+	if _, err := jobs.Start(JobRequest{Spins: 1000, Wager: 100}); !errors.Is(err, ErrTooManyJobs) {
+		t.Errorf("Start() error = %v, want ErrTooManyJobs", err)
+	}
+
+	for _, job := range started {
+		if _, err := jobs.Cancel(job.ID); err != nil {
+			t.Fatalf("Cancel() error = %v", err)
+		}
+	}
+
+	// The canceled simulations stop running once their workers return
+	deadline := time.Now().Add(10 * time.Second)
+	for _, job := range started {
+		for job.View().Status == JobRunning {
+			if time.Now().After(deadline) {
+				t.Fatal("canceled simulation is still running")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if _, err := jobs.Start(JobRequest{Spins: 1000, Wager: 100, Workers: 1}); err != nil {
+		t.Errorf("Start() after cancel error = %v", err)
+	}
+}
+
+func TestJobsPrune(t *testing.T) {
+	jobs := NewJobs(engine.DefaultGame(), rng.NewSeededService(42))
+	jobs.maxFinished = 2
+
+	now := time.Now()
+	for i, age := range []time.Duration{2 * JobTTL, 3 * time.Minute, 2 * time.Minute, time.Minute} {
+		id := string(rune('a' + i))
+		jobs.jobs[id] = &Job{ID: id, status: JobDone, finishedAt: now.Add(-age)}
+	}
+	jobs.jobs["running"] = &Job{ID: "running", status: JobRunning}
+
+	if running := jobs.prune(now); running != 1 {
+		t.Errorf("prune() = %d running, want 1", running)
+	}
+
+	for _, id := range []string{"a", "b"} {
+		if _, ok := jobs.jobs[id]; ok {
+			t.Errorf("job %s should be dropped", id)
+		}
+	}
+
+	for _, id := range []string{"c", "d", "running"} {
+		if _, ok := jobs.jobs[id]; !ok {
+			t.Errorf("job %s should be kept", id)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package simulator
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"math/big"
@@ -13,9 +14,40 @@ import (
 	"github.com/schollz/progressbar/v3"
 )
 
+// chunkSize is the number of spins a worker simulates with one RNG stream.
+// Seeded runs derive the stream from the chunk index, so their results
+// do not depend on the number of workers.
+const chunkSize = 10_000
+
+// Options tune the reports of a simulation, zero values use the defaults
+type Options struct {
+	// BucketEdges are the award histogram bucket bounds in multiples of the wager
+	BucketEdges []float64 `json:"bucket_edges"`
+	// Percentiles of the win size to report
+	Percentiles []float64 `json:"percentiles"`
+	// CheckpointInterval is the number of spins between RTP checkpoints, 1% of the spins if zero
+	CheckpointInterval int64 `json:"checkpoint_interval"`
+	// TargetCIWidth stops the simulation at the first checkpoint where the 95%
+	// confidence interval of the RTP is narrower, zero runs all spins
+	TargetCIWidth float64 `json:"target_ci_width"`
+
+	// Progress is called with the number of simulated spins after every chunk
+	// of spins and every checkpoint instead of drawing a progress bar on the
+	// terminal
+	Progress func(done int64) `json:"-"`
+
+	// SnapshotPath is the file the state of the simulation is saved to every
+	// SnapshotInterval and when the simulation ends, disabled if empty
+	SnapshotPath     string        `json:"-"`
+	SnapshotInterval time.Duration `json:"-"`
+	// Resume continues the simulation saved in the snapshot
+	Resume *Snapshot `json:"-"`
+}
+
 type SimulationResult struct {
 	Game        string   `xlsx:"Game"`
 	Count       int64    `xlsx:"Count"`
+	Requested   int64    `xlsx:"Requested Count"`
 	Wager       int64    `xlsx:"Wager"`
 	Spent       *big.Int `xlsx:"Spent"`
 	MaxExposure int64    `xlsx:"Max Exposure"`
@@ -39,11 +71,22 @@ type SimulationResult struct {
 
 	RTP         float64 `xlsx:"RTP"`
 	RTPBaseGame float64 `xlsx:"RTP Base Game"`
+
+	RTPConfidence95 ConfidenceInterval `xlsx:"-"`
+	RTPConfidence99 ConfidenceInterval `xlsx:"-"`
+	StoppedEarly    bool               `xlsx:"Stopped Early"`
+	Interrupted     bool               `xlsx:"Interrupted"`
+
+	Reelsets    []*ReelsetResult `xlsx:"-"`
+	Histogram   *Histogram       `xlsx:"-"`
+	Percentiles []Percentile     `xlsx:"-"`
+	Checkpoints []Checkpoint     `xlsx:"-"`
 }
 
 type SimulationView struct {
 	Game        string `json:"game" xlsx:"Game"`
 	Count       string `json:"count" xlsx:"Count"`
+	Requested   string `json:"requested" xlsx:"Requested Count"`
 	Wager       string `json:"wager" xlsx:"Wager"`
 	Spent       string `json:"spent" xlsx:"Spent"`
 	MaxExposure string `json:"max_exposure" xlsx:"Max Exposure"`
@@ -70,48 +113,105 @@ type SimulationView struct {
 
 	Volatility float64 `json:"volatility" xlsx:"Volatility"`
 	RTP        string  `json:"rtp" xlsx:"RTP"`
+
+	RTPConfidence95 ConfidenceIntervalView `json:"rtp_confidence_95" xlsx:"-"`
+	RTPConfidence99 ConfidenceIntervalView `json:"rtp_confidence_99" xlsx:"-"`
+	StoppedEarly    bool                   `json:"stopped_early" xlsx:"Stopped Early"`
+	Interrupted     bool                   `json:"interrupted" xlsx:"Interrupted"`
+
+	Reelsets    []ReelsetView    `json:"reelsets" xlsx:"-"`
+	Histogram   []BucketView     `json:"histogram" xlsx:"-"`
+	Percentiles []PercentileView `json:"percentiles" xlsx:"-"`
+	Checkpoints []CheckpointView `json:"checkpoints" xlsx:"-"`
 }
 
-func Simulate(game string, count int64, wager int64, workersCount int, rngService *rng.Service) (*SimulationResult, error) {
-	res := &SimulationResult{
-		Wager: wager,
-		Count: count,
-		Game:  game,
+// Simulate plays count spins on the given number of workers. When ctx is
+// canceled or a worker fails, the workers are stopped and the result of the
+// spins completed so far is returned along with the error.
+//
+// Every worker accumulates whole chunks of spins, which are merged in chunk
+// order. Seeded runs are therefore reproducible down to the checkpoints and
+// can be resumed from a snapshot with the same result.
+func Simulate(ctx context.Context, game *engine.Game, count int64, wager int64, workersCount int, rngService *rng.Service, opts Options) (*SimulationResult, error) {
+	histogram, err := newHistogram(opts.BucketEdges)
+	if err != nil {
+		return nil, err
+	}
 
-		BaseAward: new(big.Int),
-		Award:     new(big.Int),
-		Spent:     new(big.Int),
+	opts.BucketEdges = histogram.Edges
 
-		BaseAwardSquareSum: new(big.Int),
-		AwardSquareSum:     new(big.Int),
+	if len(opts.Percentiles) == 0 {
+		opts.Percentiles = DefaultPercentiles
+	}
 
-		BaseAwardStandardDeviation: new(big.Float),
-		AwardStandardDeviation:     new(big.Float),
+	for _, p := range opts.Percentiles {
+		if p <= 0 || p > 100 {
+			return nil, fmt.Errorf("percentile %v must be in (0, 100]", p)
+		}
+	}
+
+	if opts.CheckpointInterval <= 0 {
+		opts.CheckpointInterval = max(count/100, 1)
+	}
+
+	if opts.SnapshotInterval <= 0 {
+		opts.SnapshotInterval = DefaultSnapshotInterval
 	}
 
+	res := newSimulationResult(game, wager, histogram)
+	res.Requested = count
+
+	gameHash := game.Hash()
+
+	next := int64(0)
+
+	if opts.Resume != nil {
+		if err := opts.Resume.check(game, gameHash, count, wager, rngService, opts); err != nil {
+			return nil, err
+		}
+
+		if err := opts.Resume.restore(res); err != nil {
+			return nil, err
+		}
+
+		next = opts.Resume.NextChunk
+	}
+
+	// A chunk is accumulated in segments split at the checkpoint interval, so
+	// checkpoints land on its multiples even when they fall inside a chunk
 	type result struct {
-		Wager     int64
-		BaseAward int64
+		chunk    int64
+		segments []*partialResult
 	}
 
-	now := time.Now()
-	bar := progressbar.NewOptions64(count,
-		progressbar.OptionThrottle(200*time.Millisecond),
-		progressbar.OptionSetDescription("Simulating..."),
-		progressbar.OptionShowCount(),
-		progressbar.OptionSetWidth(50),
-		progressbar.OptionShowIts(),
-		progressbar.OptionOnCompletion(func() {
-			fmt.Println("\nTime elapsed:", time.Since(now))
-		}),
-	)
-
-	rngClient := rngService.GetClient()
-	spinFactory := engine.NewSpinFactoryWithAllReelsets(rngClient)
+	progress := opts.Progress
+	finishProgress := func() {}
+
+	if progress == nil {
+		now := time.Now()
+		bar := progressbar.NewOptions64(count,
+			progressbar.OptionThrottle(200*time.Millisecond),
+			progressbar.OptionSetDescription("Simulating..."),
+			progressbar.OptionShowCount(),
+			progressbar.OptionSetWidth(50),
+			progressbar.OptionShowIts(),
+			progressbar.OptionOnCompletion(func() {
+				fmt.Println("\nTime elapsed:", time.Since(now))
+			}),
+		)
+
+		progress = func(done int64) { _ = bar.Set64(done) }
+		finishProgress = func() { _ = bar.Finish() }
+	}
+
+	progress(res.Count)
 
 	inputCh := make(chan int64, workersCount)
 	outputCh := make(chan result, workersCount)
 	errCh := make(chan error, 1)
+	stopCh := make(chan struct{})
+	stop := sync.OnceFunc(func() { close(stopCh) })
+	defer stop()
 
 	wg := new(sync.WaitGroup)
 
@@ -119,27 +219,58 @@ func Simulate(game string, count int64, wager int64, workersCount int, rngServic
 		defer wg.Done()
 
 		for {
-			if _, ok := <-inputCh; !ok {
+			chunk, ok := <-inputCh
+			if !ok {
 				return
 			}
 
-			spin, err := spinFactory.Generate(wager)
-			if err != nil {
-				errCh <- err
-				return
+			spinFactory := engine.NewSpinFactoryFromGame(game, rngService.Stream(uint64(chunk)))
+			var segments []*partialResult
+			var partial *partialResult
+
+			for i := chunk * chunkSize; i < min((chunk+1)*chunkSize, count); i++ {
+				select {
+				case <-stopCh:
+					return
+				default:
+				}
+
+				if partial == nil || i%opts.CheckpointInterval == 0 {
+					partial = newPartialResult(len(game.ReelsetData), histogram)
+					segments = append(segments, partial)
+				}
+
+				spin, err := spinFactory.Generate(wager)
+				if err != nil {
+					select {
+					case errCh <- err:
+					default:
+					}
+					return
+				}
+
+				partial.add(spin)
 			}
 
-			outputCh <- result{
-				Wager:     spin.Wager,
-				BaseAward: spin.Award,
+			select {
+			case outputCh <- result{chunk: chunk, segments: segments}:
+			case <-stopCh:
+				return
 			}
 		}
 	}
 
+	// A resumed simulation that already stopped early only reports
+	first, finished := next, res.StoppedEarly
+
 	go func() {
 		defer close(inputCh)
-		for i := int64(0); i < count; i++ {
-			inputCh <- i
+		for chunk := first; chunk*chunkSize < count && !finished; chunk++ {
+			select {
+			case inputCh <- chunk:
+			case <-stopCh:
+				return
+			}
 		}
 	}()
 
@@ -152,7 +283,12 @@ func Simulate(game string, count int64, wager int64, workersCount int, rngServic
 		close(outputCh)
 	}()
 
-	i := 0
+	// pending holds the chunks finished ahead of the next one to merge
+	pending := make(map[int64][]*partialResult)
+	lastSave := time.Now()
+
+	// simErr is the first worker error or the cancellation of ctx
+	var simErr error
 
 Loop:
 	for {
@@ -162,67 +298,145 @@ Loop:
 				break Loop
 			}
 
-			award := output.BaseAward
+			pending[output.chunk] = output.segments
+
+			for {
+				segments, ok := pending[next]
+				if !ok {
+					break
+				}
+
+				delete(pending, next)
+				next++
+
+				for _, partial := range segments {
+					res.merge(partial)
+					progress(res.Count)
+
+					if res.Count%opts.CheckpointInterval == 0 || res.Count == count {
+						checkpoint := newCheckpoint(res.Count, res.Award, res.AwardSquareSum, wager)
+						res.Checkpoints = append(res.Checkpoints, checkpoint)
+
+						if opts.TargetCIWidth > 0 && res.Count < count && checkpoint.Confidence95.Width() < opts.TargetCIWidth {
+							res.StoppedEarly = true
+							finishProgress()
+							break Loop
+						}
+					}
+				}
+
+				if opts.SnapshotPath != "" && time.Since(lastSave) >= opts.SnapshotInterval {
+					saveSnapshot(opts.SnapshotPath, res, gameHash, next, rngService, opts)
+					lastSave = time.Now()
+				}
+			}
+		case err := <-errCh:
+			simErr = err
+			break Loop
+		case <-ctx.Done():
+			simErr = ctx.Err()
+			break Loop
+		}
+	}
 
-			res.BaseAward.Add(res.BaseAward, big.NewInt(output.BaseAward))
-			res.Award.Add(res.Award, big.NewInt(award))
-			res.Spent.Add(res.Spent, big.NewInt(output.Wager))
+	// Wait for the workers, chunks still in flight are not counted
+	stop()
+	for range outputCh {
+	}
 
-			if award > res.MaxExposure {
-				res.MaxExposure = award
-			}
+	// A worker can fail right before the output channel is closed
+	if simErr == nil {
+		select {
+		case simErr = <-errCh:
+		default:
+		}
+	}
 
-			if award > 0 {
-				res.BaseAwardCount++
-			}
+	if simErr == nil && !res.StoppedEarly && res.Count < count {
+		simErr = fmt.Errorf("simulated %d of %d spins", res.Count, count)
+	}
 
-			if award >= wager*1 {
-				res.X1Count++
-			}
+	if opts.SnapshotPath != "" {
+		saveSnapshot(opts.SnapshotPath, res, gameHash, next, rngService, opts)
+	}
 
-			if award >= wager*10 {
-				res.X10Count++
-			}
+	res.Interrupted = simErr != nil
+	res.finalize(opts.Percentiles)
 
-			if award >= wager*100 {
-				res.X100Count++
-			}
+	return res, simErr
+}
 
-			res.BaseAwardSquareSum.Add(res.BaseAwardSquareSum, big.NewInt(0).Mul(big.NewInt(output.BaseAward), big.NewInt(output.BaseAward)))
-			res.AwardSquareSum.Add(res.AwardSquareSum, big.NewInt(0).Mul(big.NewInt(award), big.NewInt(award)))
+func newSimulationResult(game *engine.Game, wager int64, histogram *Histogram) *SimulationResult {
+	return &SimulationResult{
+		Wager: wager,
+		Game:  game.Name,
 
-			_ = bar.Add(1)
-			i++
-		case err := <-errCh:
-			return nil, err
-		}
+		BaseAward: new(big.Int),
+		Award:     new(big.Int),
+		Spent:     new(big.Int),
+
+		BaseAwardSquareSum: new(big.Int),
+		AwardSquareSum:     new(big.Int),
+
+		BaseAwardStandardDeviation: new(big.Float),
+		AwardStandardDeviation:     new(big.Float),
+		Volatility:                 new(big.Float),
+
+		Reelsets:  newReelsetResults(game),
+		Histogram: histogram,
+	}
+}
+
+// finalize computes the statistics of the simulated spins from the accumulated sums
+func (r *SimulationResult) finalize(percentiles []float64) {
+	if r.Count == 0 {
+		return
 	}
 
-	baseMeanB := new(big.Float).SetInt(res.BaseAward)
-	totalMeanB := new(big.Float).SetInt(res.Award)
+	baseMeanB := new(big.Float).SetInt(r.BaseAward)
+	totalMeanB := new(big.Float).SetInt(r.Award)
+
+	baseMeanB.Quo(baseMeanB, big.NewFloat(float64(r.Count)))
+	totalMeanB.Quo(totalMeanB, big.NewFloat(float64(r.Count)))
 
-	baseMeanB.Quo(baseMeanB, big.NewFloat(float64(res.Count)))
-	totalMeanB.Quo(totalMeanB, big.NewFloat(float64(res.Count)))
+	r.BaseAwardStandardDeviation = StandardDeviation(r.BaseAwardSquareSum, r.BaseAward, baseMeanB, r.Count)
+	r.AwardStandardDeviation = StandardDeviation(r.AwardSquareSum, r.Award, totalMeanB, r.Count)
 
-	res.BaseAwardStandardDeviation = StandardDeviation(res.BaseAwardSquareSum, res.BaseAward, baseMeanB, res.Count)
-	res.AwardStandardDeviation = StandardDeviation(res.AwardSquareSum, res.Award, totalMeanB, res.Count)
+	r.Volatility = new(big.Float).Quo(r.AwardStandardDeviation, new(big.Float).SetInt64(r.Wager))
 
-	res.Volatility = new(big.Float).Quo(res.AwardStandardDeviation, new(big.Float).SetInt64(wager))
+	awardF := new(big.Float).SetInt(r.Award)
+	baseF := new(big.Float).SetInt(r.BaseAward)
+	spentF := new(big.Float).SetInt(r.Spent)
 
-	awardF := new(big.Float).SetInt(res.Award)
-	baseF := new(big.Float).SetInt(res.BaseAward)
-	spentF := new(big.Float).SetInt(res.Spent)
+	r.RTP, _ = new(big.Float).Quo(awardF, spentF).Float64()
+	r.RTPBaseGame, _ = new(big.Float).Quo(baseF, spentF).Float64()
 
-	res.RTP, _ = new(big.Float).Quo(awardF, spentF).Float64()
-	res.RTPBaseGame, _ = new(big.Float).Quo(baseF, spentF).Float64()
+	for _, reelset := range r.Reelsets {
+		reelset.calculateRTP(r.Wager)
+	}
 
-	return res, nil
+	r.RTPConfidence95 = rtpConfidenceInterval(r.Award, r.AwardSquareSum, r.Count, r.Wager, z95)
+	r.RTPConfidence99 = rtpConfidenceInterval(r.Award, r.AwardSquareSum, r.Count, r.Wager, z99)
+
+	r.Histogram.calculateRTP(r.Spent)
+	r.Percentiles = r.Histogram.Percentiles(percentiles)
 }
 
 func (r SimulationResult) View() *SimulationView {
+	reelsetCount := int64(0)
+	for _, reelset := range r.Reelsets {
+		reelsetCount += reelset.Count
+	}
+
+	reelsets := make([]ReelsetView, 0, len(r.Reelsets))
+	for _, reelset := range r.Reelsets {
+		reelsets = append(reelsets, reelset.View(reelsetCount))
+	}
+
 	return &SimulationView{
 		Game:        r.Game,
 		Count:       fmt.Sprint(r.Count),
+		Requested:   fmt.Sprint(r.Requested),
 		Wager:       fmt.Sprint(r.Wager),
 		Spent:       fmt.Sprint(r.Spent),
 		MaxExposure: fmt.Sprint(r.MaxExposure),
@@ -249,6 +463,16 @@ func (r SimulationResult) View() *SimulationView {
 
 		Volatility: float64FromBigFloat(r.Volatility, 3),
 		RTP:        floatWithPrecision(r.RTP),
+
+		Reelsets:    reelsets,
+		Histogram:   r.Histogram.View(r.Count),
+		Percentiles: percentilesView(r.Percentiles, r.Wager),
+		Checkpoints: checkpointsView(r.Checkpoints),
+
+		RTPConfidence95: r.RTPConfidence95.View(),
+		RTPConfidence99: r.RTPConfidence99.View(),
+		StoppedEarly:    r.StoppedEarly,
+		Interrupted:     r.Interrupted,
 	}
 }
 
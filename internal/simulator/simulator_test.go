@@ -0,0 +1,281 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"piggy-bank/internal/engine"
+	"piggy-bank/internal/rng"
+)
+
+const testSpins = 5*chunkSize + 1234
+
+func simulateSeeded(t *testing.T, ctx context.Context, workers int, opts Options) (*SimulationResult, error) {
+	t.Helper()
+
+	if opts.Progress == nil {
+		opts.Progress = func(int64) {}
+	}
+
+	return Simulate(ctx, engine.DefaultGame(), testSpins, 100, workers, rng.NewSeededService(42), opts)
+}
+
+func TestSimulateReproducible(t *testing.T) {
+	one, err := simulateSeeded(t, context.Background(), 1, Options{})
+	if err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+
+	many, err := simulateSeeded(t, context.Background(), 8, Options{})
+	if err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+
+	if one.Count != testSpins {
+		t.Errorf("Count = %d, want %d", one.Count, testSpins)
+	}
+
+	if !reflect.DeepEqual(one.View(), many.View()) {
+		t.Error("seeded simulations with 1 and 8 workers differ")
+	}
+}
+
+func TestSimulateResume(t *testing.T) {
+	full, err := simulateSeeded(t, context.Background(), 4, Options{})
+	if err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	// Cancel once a few chunks are merged, the snapshot is saved on the way out
+	ctx, cancel := context.WithCancel(context.Background())
+	partial, err := simulateSeeded(t, ctx, 4, Options{
+		SnapshotPath: path,
+		Progress: func(done int64) {
+			if done >= 2*chunkSize {
+				cancel()
+			}
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Simulate() error = %v, want context.Canceled", err)
+	}
+
+	if !partial.Interrupted || partial.Count == 0 || partial.Count >= testSpins || partial.Count%chunkSize != 0 {
+		t.Fatalf("partial Count = %d, Interrupted = %v, want whole chunks of an interrupted run", partial.Count, partial.Interrupted)
+	}
+
+	snapshot, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("LoadSnapshot() error = %v", err)
+	}
+
+	resumed, err := simulateSeeded(t, context.Background(), 2, Options{Resume: snapshot})
+	if err != nil {
+		t.Fatalf("Simulate() resumed error = %v", err)
+	}
+
+	if !reflect.DeepEqual(full.View(), resumed.View()) {
+		t.Error("resumed simulation differs from the uninterrupted one")
+	}
+
+	_, err = Simulate(context.Background(), engine.DefaultGame(), testSpins, 100, 2, rng.NewSeededService(7), Options{Resume: snapshot, Progress: func(int64) {}})
+	if err == nil {
+		t.Error("Simulate() resumed with a different seed should fail")
+	}
+
+	changed := engine.DefaultGame()
+	changed.MaxFreeSpins++
+	_, err = Simulate(context.Background(), changed, testSpins, 100, 2, rng.NewSeededService(42), Options{Resume: snapshot, Progress: func(int64) {}})
+	if err == nil {
+		t.Error("Simulate() resumed on a changed game should fail")
+	}
+
+	relayout := *snapshot
+	relayout.LayoutVersion = engine.DrawLayoutVersion + 1
+	_, err = simulateSeeded(t, context.Background(), 2, Options{Resume: &relayout})
+	if err == nil {
+		t.Error("Simulate() resumed from another draw layout should fail")
+	}
+}
+
+func TestSimulateCheckpointInterval(t *testing.T) {
+	// The interval does not divide the chunk size, checkpoints still have to
+	// land on its multiples
+	const interval = 2_500
+
+	res, err := simulateSeeded(t, context.Background(), 4, Options{CheckpointInterval: interval})
+	if err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+
+	var spins []int64
+	for _, checkpoint := range res.Checkpoints {
+		spins = append(spins, checkpoint.Spins)
+	}
+
+	var want []int64
+	for n := int64(interval); n < testSpins; n += interval {
+		want = append(want, n)
+	}
+	want = append(want, testSpins)
+
+	if !reflect.DeepEqual(spins, want) {
+		t.Errorf("checkpoints at %v, want %v", spins, want)
+	}
+}
+
+func TestSimulateWorkerError(t *testing.T) {
+	// Every spin of a zero wager fails, the error must not be lost when the
+	// workers finish before the collector reads it
+	for i := 0; i < 20; i++ {
+		res, err := Simulate(context.Background(), engine.DefaultGame(), testSpins, 0, 4, rng.NewSeededService(42), Options{Progress: func(int64) {}})
+		if err == nil {
+			t.Fatalf("Simulate() error = nil with Count = %d, want the worker error", res.Count)
+		}
+
+		if !res.Interrupted {
+			t.Error("Interrupted = false, want true")
+		}
+	}
+}
+
+func TestWideSumPromotion(t *testing.T) {
+	var sum, squares wideSum
+
+	want := new(big.Int)
+	wantSquares := new(big.Int)
+
+	for _, v := range []int64{math.MaxInt64, math.MaxInt64, 5, math.MaxInt64, 1 << 40} {
+		sum.add(v)
+		squares.addSquare(v)
+
+		want.Add(want, big.NewInt(v))
+		wantSquares.Add(wantSquares, new(big.Int).Mul(big.NewInt(v), big.NewInt(v)))
+	}
+
+	got, gotSquares := new(big.Int), new(big.Int)
+	sum.addTo(got)
+	squares.addTo(gotSquares)
+
+	if got.Cmp(want) != 0 {
+		t.Errorf("sum = %v, want %v", got, want)
+	}
+
+	if gotSquares.Cmp(wantSquares) != 0 {
+		t.Errorf("square sum = %v, want %v", gotSquares, wantSquares)
+	}
+}
+
+// simulatePerSpin is the collector of the simulator before spins were
+// aggregated per chunk: every spin is sent to one goroutine that adds it up
+// in big.Int, kept to benchmark against Simulate
+func simulatePerSpin(game *engine.Game, count, wager int64, workersCount int, rngService *rng.Service) (*big.Int, error) {
+	histogram, err := newHistogram(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reelset and histogram counts of the spins, the totals are kept in big.Int below
+	breakdown := newPartialResult(len(game.ReelsetData), histogram)
+
+	inputCh := make(chan int64, workersCount)
+	outputCh := make(chan *engine.Spin, workersCount)
+	errCh := make(chan error, workersCount)
+
+	go func() {
+		defer close(inputCh)
+		for chunk := int64(0); chunk*chunkSize < count; chunk++ {
+			inputCh <- chunk
+		}
+	}()
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < workersCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for chunk := range inputCh {
+				spinFactory := engine.NewSpinFactoryFromGame(game, rngService.Stream(uint64(chunk)))
+
+				for i := chunk * chunkSize; i < min((chunk+1)*chunkSize, count); i++ {
+					spin, err := spinFactory.Generate(wager)
+					if err != nil {
+						errCh <- err
+						return
+					}
+
+					outputCh <- spin
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(outputCh)
+	}()
+
+	award, baseAward, spent := new(big.Int), new(big.Int), new(big.Int)
+	awardSquareSum, baseAwardSquareSum := new(big.Int), new(big.Int)
+
+	for spin := range outputCh {
+		base := spin.BaseAward()
+
+		award.Add(award, big.NewInt(spin.Award))
+		baseAward.Add(baseAward, big.NewInt(base))
+		spent.Add(spent, big.NewInt(spin.Wager))
+
+		awardSquareSum.Add(awardSquareSum, big.NewInt(0).Mul(big.NewInt(spin.Award), big.NewInt(spin.Award)))
+		baseAwardSquareSum.Add(baseAwardSquareSum, big.NewInt(0).Mul(big.NewInt(base), big.NewInt(base)))
+
+		breakdown.add(spin)
+	}
+
+	select {
+	case err := <-errCh:
+		return nil, err
+	default:
+	}
+
+	return award, nil
+}
+
+func BenchmarkSimulate(b *testing.B) {
+	// A few hundred chunks, so every worker count gets many chunks each
+	const spins = 320 * chunkSize
+
+	game := engine.DefaultGame()
+	opts := Options{Progress: func(int64) {}}
+
+	for _, workers := range []int{1, 8, 32} {
+		b.Run(fmt.Sprintf("chunks/workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := Simulate(context.Background(), game, spins, 100, workers, rng.NewSeededService(uint64(i)), opts); err != nil {
+					b.Fatalf("Simulate() error = %v", err)
+				}
+			}
+
+			b.ReportMetric(float64(spins)*float64(b.N)/b.Elapsed().Seconds(), "spins/s")
+		})
+
+		b.Run(fmt.Sprintf("per-spin/workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := simulatePerSpin(game, spins, 100, workers, rng.NewSeededService(uint64(i))); err != nil {
+					b.Fatalf("simulatePerSpin() error = %v", err)
+				}
+			}
+
+			b.ReportMetric(float64(spins)*float64(b.N)/b.Elapsed().Seconds(), "spins/s")
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package simulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"slices"
+	"time"
+
+	"piggy-bank/internal/engine"
+	"piggy-bank/internal/rng"
+)
+
+// DefaultSnapshotInterval is how often a running simulation is saved
+const DefaultSnapshotInterval = time.Minute
+
+const snapshotVersion = 2
+
+// Snapshot is the saved state of a simulation. The spins of every chunk
+// before NextChunk are accumulated in Result, seeded runs restart the RNG
+// streams from NextChunk. GameHash and LayoutVersion pin the math and the
+// draw layout the spins were simulated with.
+type Snapshot struct {
+	Version       int       `json:"version"`
+	Game          string    `json:"game"`
+	GameHash      string    `json:"game_hash"`
+	LayoutVersion int       `json:"layout_version"`
+	Count         int64     `json:"count"`
+	Wager         int64     `json:"wager"`
+	Seed          *uint64   `json:"seed,omitempty"`
+	Options       Options   `json:"options"`
+	NextChunk     int64     `json:"next_chunk"`
+	SavedAt       time.Time `json:"saved_at"`
+
+	Result snapshotResult `json:"result"`
+}
+
+type snapshotResult struct {
+	Count          int64 `json:"count"`
+	MaxExposure    int64 `json:"max_exposure"`
+	BaseAwardCount int64 `json:"base_award_count"`
+	X1Count        int64 `json:"x1_count"`
+	X10Count       int64 `json:"x10_count"`
+	X100Count      int64 `json:"x100_count"`
+	StoppedEarly   bool  `json:"stopped_early"`
+
+	Spent              *big.Int `json:"spent"`
+	BaseAward          *big.Int `json:"base_award"`
+	Award              *big.Int `json:"award"`
+	BaseAwardSquareSum *big.Int `json:"base_award_square_sum"`
+	AwardSquareSum     *big.Int `json:"award_square_sum"`
+
+	Reelsets    []snapshotReelset `json:"reelsets"`
+	Buckets     []snapshotBucket  `json:"buckets"`
+	Awards      map[int64]int64   `json:"awards"`
+	Checkpoints []Checkpoint      `json:"checkpoints"`
+}
+
+type snapshotReelset struct {
+	Count      int64            `json:"count"`
+	BaseCount  int64            `json:"base_count"`
+	AwardCount int64            `json:"award_count"`
+	Award      *big.Int         `json:"award"`
+	Symbols    []snapshotSymbol `json:"symbols"`
+}
+
+type snapshotSymbol struct {
+	Symbol engine.Symbol `json:"symbol"`
+	Length int           `json:"length"`
+	Hits   int64         `json:"hits"`
+	Award  *big.Int      `json:"award"`
+}
+
+type snapshotBucket struct {
+	Count int64    `json:"count"`
+	Award *big.Int `json:"award"`
+}
+
+// LoadSnapshot reads a snapshot saved by a simulation with Options.SnapshotPath
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read simulation snapshot: %w", err)
+	}
+
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("corrupted simulation snapshot %s: %w", path, err)
+	}
+
+	if snapshot.Version != snapshotVersion {
+		return nil, fmt.Errorf("simulation snapshot %s has version %d, expected %d", path, snapshot.Version, snapshotVersion)
+	}
+
+	return snapshot, nil
+}
+
+func newSnapshot(res *SimulationResult, gameHash string, next int64, rngService *rng.Service, opts Options) *Snapshot {
+	snapshot := &Snapshot{
+		Version:       snapshotVersion,
+		Game:          res.Game,
+		GameHash:      gameHash,
+		LayoutVersion: engine.DrawLayoutVersion,
+		Count:         res.Requested,
+		Wager:         res.Wager,
+		Options:       opts,
+		NextChunk:     next,
+		SavedAt:       time.Now(),
+
+		Result: snapshotResult{
+			Count:          res.Count,
+			MaxExposure:    res.MaxExposure,
+			BaseAwardCount: res.BaseAwardCount,
+			X1Count:        res.X1Count,
+			X10Count:       res.X10Count,
+			X100Count:      res.X100Count,
+			StoppedEarly:   res.StoppedEarly,
+
+			Spent:              res.Spent,
+			BaseAward:          res.BaseAward,
+			Award:              res.Award,
+			BaseAwardSquareSum: res.BaseAwardSquareSum,
+			AwardSquareSum:     res.AwardSquareSum,
+
+			Awards:      res.Histogram.awards,
+			Checkpoints: res.Checkpoints,
+		},
+	}
+
+	if seed, ok := rngService.Seed(); ok {
+		snapshot.Seed = &seed
+	}
+
+	for _, reelset := range res.Reelsets {
+		saved := snapshotReelset{
+			Count:      reelset.Count,
+			BaseCount:  reelset.BaseCount,
+			AwardCount: reelset.AwardCount,
+			Award:      reelset.Award,
+		}
+
+		for _, symbol := range reelset.SortedSymbols() {
+			saved.Symbols = append(saved.Symbols, snapshotSymbol{
+				Symbol: symbol.Symbol,
+				Length: symbol.Length,
+				Hits:   symbol.Hits,
+				Award:  symbol.Award,
+			})
+		}
+
+		snapshot.Result.Reelsets = append(snapshot.Result.Reelsets, saved)
+	}
+
+	for _, bucket := range res.Histogram.Buckets {
+		snapshot.Result.Buckets = append(snapshot.Result.Buckets, snapshotBucket{Count: bucket.Count, Award: bucket.Award})
+	}
+
+	return snapshot
+}
+
+// saveSnapshot writes the snapshot next to path and renames it over path, so an
+// interrupted write keeps the previous snapshot. Failures are logged, they
+// should not stop the simulation.
+func saveSnapshot(path string, res *SimulationResult, gameHash string, next int64, rngService *rng.Service, opts Options) {
+	data, err := json.Marshal(newSnapshot(res, gameHash, next, rngService, opts))
+	if err == nil {
+		tmp := path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o644); err == nil {
+			err = os.Rename(tmp, path)
+		}
+	}
+
+	if err != nil {
+		log.Printf("Failed to save simulation snapshot %s: %v", path, err)
+	}
+}
+
+// check verifies that the snapshot was taken from a simulation with the same parameters
+func (s *Snapshot) check(game *engine.Game, gameHash string, count, wager int64, rngService *rng.Service, opts Options) error {
+	var errs []error
+
+	if s.Game != game.Name {
+		errs = append(errs, fmt.Errorf("snapshot is of game %s, not %s", s.Game, game.Name))
+	} else if s.GameHash != gameHash {
+		errs = append(errs, fmt.Errorf("snapshot is of game %s with hash %s, the game has changed to %s", s.Game, s.GameHash, gameHash))
+	}
+
+	if s.LayoutVersion != engine.DrawLayoutVersion {
+		errs = append(errs, fmt.Errorf("snapshot was taken with draw layout %d, not %d", s.LayoutVersion, engine.DrawLayoutVersion))
+	}
+
+	if s.Count != count || s.Wager != wager {
+		errs = append(errs, fmt.Errorf("snapshot simulates %d spins with wager %d, not %d spins with wager %d", s.Count, s.Wager, count, wager))
+	}
+
+	seed, seeded := rngService.Seed()
+	switch {
+	case s.Seed == nil && seeded:
+		errs = append(errs, errors.New("snapshot was not seeded, the simulation is"))
+	case s.Seed != nil && !seeded:
+		errs = append(errs, fmt.Errorf("snapshot was seeded with %d, the simulation is not", *s.Seed))
+	case s.Seed != nil && *s.Seed != seed:
+		errs = append(errs, fmt.Errorf("snapshot was seeded with %d, not %d", *s.Seed, seed))
+	}
+
+	if !slices.Equal(s.Options.BucketEdges, opts.BucketEdges) ||
+		s.Options.CheckpointInterval != opts.CheckpointInterval ||
+		s.Options.TargetCIWidth != opts.TargetCIWidth {
+		errs = append(errs, errors.New("snapshot has different histogram buckets, checkpoint interval or target confidence interval width"))
+	}
+
+	if len(s.Result.Reelsets) != len(game.Reelsets) {
+		errs = append(errs, fmt.Errorf("snapshot has %d reelsets, game has %d", len(s.Result.Reelsets), len(game.Reelsets)))
+	}
+
+	return errors.Join(errs...)
+}
+
+// restore sets the accumulated spins of the snapshot on an empty result
+func (s *Snapshot) restore(res *SimulationResult) error {
+	saved := s.Result
+
+	if len(saved.Buckets) != len(res.Histogram.Buckets) {
+		return fmt.Errorf("snapshot has %d histogram buckets, expected %d", len(saved.Buckets), len(res.Histogram.Buckets))
+	}
+
+	ints := []*big.Int{saved.Spent, saved.BaseAward, saved.Award, saved.BaseAwardSquareSum, saved.AwardSquareSum}
+	if slices.Contains(ints, nil) {
+		return errors.New("snapshot is missing accumulated sums")
+	}
+
+	res.Count = saved.Count
+	res.MaxExposure = saved.MaxExposure
+	res.BaseAwardCount = saved.BaseAwardCount
+	res.X1Count = saved.X1Count
+	res.X10Count = saved.X10Count
+	res.X100Count = saved.X100Count
+	res.StoppedEarly = saved.StoppedEarly
+
+	res.Spent.Set(saved.Spent)
+	res.BaseAward.Set(saved.BaseAward)
+	res.Award.Set(saved.Award)
+	res.BaseAwardSquareSum.Set(saved.BaseAwardSquareSum)
+	res.AwardSquareSum.Set(saved.AwardSquareSum)
+
+	for i, reelset := range saved.Reelsets {
+		restored := res.Reelsets[i]
+		restored.Count = reelset.Count
+		restored.BaseCount = reelset.BaseCount
+		restored.AwardCount = reelset.AwardCount
+		if reelset.Award != nil {
+			restored.Award.Set(reelset.Award)
+		}
+
+		for _, symbol := range reelset.Symbols {
+			award := new(big.Int)
+			if symbol.Award != nil {
+				award.Set(symbol.Award)
+			}
+
+			restored.Symbols[SymbolKey{Symbol: symbol.Symbol, Length: symbol.Length}] = &SymbolResult{
+				Symbol: symbol.Symbol,
+				Length: symbol.Length,
+				Hits:   symbol.Hits,
+				Award:  award,
+			}
+		}
+	}
+
+	for i, bucket := range saved.Buckets {
+		res.Histogram.Buckets[i].Count = bucket.Count
+		if bucket.Award != nil {
+			res.Histogram.Buckets[i].Award.Set(bucket.Award)
+		}
+	}
+
+	for award, count := range saved.Awards {
+		res.Histogram.awards[award] = count
+	}
+
+	res.Checkpoints = append([]Checkpoint(nil), saved.Checkpoints...)
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package wallet
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type TransactionType string
+
+const (
+	Deposit TransactionType = "deposit"
+	Debit   TransactionType = "debit"
+	Credit  TransactionType = "credit"
+)
+
+// Entry is a balance change requested by the wallet
+type Entry struct {
+	Type      TransactionType
+	Amount    int64
+	Reference string
+}
+
+// Transaction is an Entry applied to the ledger
+type Transaction struct {
+	ID        int64           `json:"id"`
+	PlayerID  string          `json:"player_id"`
+	Type      TransactionType `json:"type"`
+	Amount    int64           `json:"amount"`
+	Balance   int64           `json:"balance"`
+	Reference string          `json:"reference,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Storage persists player balances and the transaction ledger
+type Storage interface {
+	Balance(playerID string) (int64, error)
+	// Update runs fn with the current balance while holding the player's lock
+	// and applies all returned entries at once, or none of them on error
+	Update(playerID string, fn func(balance int64) ([]Entry, error)) ([]Transaction, error)
+	Transactions(playerID string) ([]Transaction, error)
+}
+
+type account struct {
+	mu           sync.Mutex
+	balance      int64
+	transactions []Transaction
+}
+
+// MemoryStorage keeps the ledger in memory
+type MemoryStorage struct {
+	mu       sync.Mutex
+	accounts map[string]*account
+	nextID   int64
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{accounts: make(map[string]*account)}
+}
+
+func (s *MemoryStorage) account(playerID string) *account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[playerID]
+	if !ok {
+		acc = &account{}
+		s.accounts[playerID] = acc
+	}
+
+	return acc
+}
+
+func (s *MemoryStorage) Balance(playerID string) (int64, error) {
+	acc := s.account(playerID)
+
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	return acc.balance, nil
+}
+
+func (s *MemoryStorage) Transactions(playerID string) ([]Transaction, error) {
+	acc := s.account(playerID)
+
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	transactions := make([]Transaction, len(acc.transactions))
+	copy(transactions, acc.transactions)
+
+	return transactions, nil
+}
+
+func (s *MemoryStorage) Update(playerID string, fn func(balance int64) ([]Entry, error)) ([]Transaction, error) {
+	return s.update(playerID, fn, nil)
+}
+
+// update applies the entries returned by fn. persist, if set, is called with the
+// resulting transactions before they become visible and can reject them.
+func (s *MemoryStorage) update(playerID string, fn func(balance int64) ([]Entry, error), persist func([]Transaction) error) ([]Transaction, error) {
+	acc := s.account(playerID)
+
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	entries, err := fn(acc.balance)
+	if err != nil {
+		return nil, err
+	}
+
+	balance := acc.balance
+	transactions := make([]Transaction, 0, len(entries))
+	now := time.Now()
+
+	for _, entry := range entries {
+		switch entry.Type {
+		case Deposit, Credit:
+			balance += entry.Amount
+		case Debit:
+			balance -= entry.Amount
+		default:
+			return nil, fmt.Errorf("unknown transaction type %q", entry.Type)
+		}
+
+		if balance < 0 {
+			return nil, ErrInsufficientFunds
+		}
+
+		transactions = append(transactions, Transaction{
+			ID:        s.newID(),
+			PlayerID:  playerID,
+			Type:      entry.Type,
+			Amount:    entry.Amount,
+			Balance:   balance,
+			Reference: entry.Reference,
+			CreatedAt: now,
+		})
+	}
+
+	if persist != nil {
+		if err := persist(transactions); err != nil {
+			return nil, err
+		}
+	}
+
+	acc.balance = balance
+	acc.transactions = append(acc.transactions, transactions...)
+
+	return transactions, nil
+}
+
+func (s *MemoryStorage) newID() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+
+	return s.nextID
+}
+
+// restore replays a transaction read from persistent storage
+func (s *MemoryStorage) restore(tx Transaction) {
+	acc := s.account(tx.PlayerID)
+	acc.balance = tx.Balance
+	acc.transactions = append(acc.transactions, tx)
+
+	s.mu.Lock()
+	if tx.ID > s.nextID {
+		s.nextID = tx.ID
+	}
+	s.mu.Unlock()
+}
+
+// FileStorage keeps the ledger in memory and appends every update to a
+// JSON lines file, one line per update, which is replayed on start
+type FileStorage struct {
+	*MemoryStorage
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileStorage(path string) (*FileStorage, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open wallet ledger: %w", err)
+	}
+
+	s := &FileStorage{
+		MemoryStorage: NewMemoryStorage(),
+		file:          file,
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		var transactions []Transaction
+		if err := json.Unmarshal(scanner.Bytes(), &transactions); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("corrupted wallet ledger %s at line %d: %w", path, line, err)
+		}
+
+		for _, tx := range transactions {
+			s.restore(tx)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read wallet ledger: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *FileStorage) Update(playerID string, fn func(balance int64) ([]Entry, error)) ([]Transaction, error) {
+	return s.MemoryStorage.update(playerID, fn, s.append)
+}
+
+func (s *FileStorage) append(transactions []Transaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(transactions)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write wallet ledger: %w", err)
+	}
+
+	return s.file.Sync()
+}
+
+func (s *FileStorage) Close() error {
+	return s.file.Close()
+}
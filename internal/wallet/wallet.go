@@ -0,0 +1,74 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrInvalidAmount     = errors.New("amount must be positive")
+)
+
+// Wallet moves money between players and the game on top of a Storage
+type Wallet struct {
+	storage Storage
+}
+
+func NewWallet(storage Storage) *Wallet {
+	return &Wallet{storage: storage}
+}
+
+func (w *Wallet) Balance(playerID string) (int64, error) {
+	return w.storage.Balance(playerID)
+}
+
+func (w *Wallet) Transactions(playerID string) ([]Transaction, error) {
+	return w.storage.Transactions(playerID)
+}
+
+func (w *Wallet) Deposit(playerID string, amount int64) (int64, error) {
+	if amount <= 0 {
+		return 0, ErrInvalidAmount
+	}
+
+	transactions, err := w.storage.Update(playerID, func(balance int64) ([]Entry, error) {
+		return []Entry{{Type: Deposit, Amount: amount}}, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return transactions[len(transactions)-1].Balance, nil
+}
+
+// Play debits the wager, runs play and credits the award it returns in a single
+// ledger update. play is not called when the balance does not cover the wager.
+func (w *Wallet) Play(playerID string, wager int64, reference string, play func() (int64, error)) (int64, error) {
+	if wager <= 0 {
+		return 0, ErrInvalidAmount
+	}
+
+	transactions, err := w.storage.Update(playerID, func(balance int64) ([]Entry, error) {
+		if balance < wager {
+			return nil, ErrInsufficientFunds
+		}
+
+		award, err := play()
+		if err != nil {
+			return nil, err
+		}
+
+		entries := []Entry{{Type: Debit, Amount: wager, Reference: reference}}
+		if award > 0 {
+			entries = append(entries, Entry{Type: Credit, Amount: award, Reference: reference})
+		}
+
+		return entries, nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to play for %s: %w", playerID, err)
+	}
+
+	return transactions[len(transactions)-1].Balance, nil
+}
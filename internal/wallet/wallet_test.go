@@ -0,0 +1,81 @@
+package wallet
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestWalletPlay(t *testing.T) {
+	w := NewWallet(NewMemoryStorage())
+
+	if _, err := w.Deposit("player", 100); err != nil {
+		t.Fatalf("Wallet.Deposit() error = %v", err)
+	}
+
+	balance, err := w.Play("player", 60, "spin-1", func() (int64, error) { return 30, nil })
+	if err != nil {
+		t.Fatalf("Wallet.Play() error = %v", err)
+	}
+
+	if balance != 70 {
+		t.Errorf("balance = %v, want 70", balance)
+	}
+
+	played := false
+	_, err = w.Play("player", 80, "spin-2", func() (int64, error) {
+		played = true
+		return 0, nil
+	})
+
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Wallet.Play() error = %v, want %v", err, ErrInsufficientFunds)
+	}
+
+	if played {
+		t.Error("spin was played with insufficient funds")
+	}
+
+	if balance, _ := w.Balance("player"); balance != 70 {
+		t.Errorf("balance after rejected spin = %v, want 70", balance)
+	}
+
+	transactions, _ := w.Transactions("player")
+	if len(transactions) != 3 {
+		t.Errorf("len(transactions) = %v, want 3", len(transactions))
+	}
+}
+
+func TestFileStorageReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.jsonl")
+
+	storage, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileStorage() error = %v", err)
+	}
+
+	w := NewWallet(storage)
+	w.Deposit("player", 100)
+	w.Play("player", 10, "spin-1", func() (int64, error) { return 25, nil })
+	w.Play("player", 10, "spin-2", func() (int64, error) { return 0, errors.New("rng failed") })
+	storage.Close()
+
+	storage, err = NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileStorage() error = %v", err)
+	}
+	defer storage.Close()
+
+	if balance, _ := storage.Balance("player"); balance != 115 {
+		t.Errorf("balance = %v, want 115", balance)
+	}
+
+	transactions, _ := storage.Transactions("player")
+	if len(transactions) != 3 {
+		t.Fatalf("len(transactions) = %v, want 3", len(transactions))
+	}
+
+	if tx := transactions[2]; tx.Type != Credit || tx.Amount != 25 || tx.Reference != "spin-1" || tx.ID != 3 {
+		t.Errorf("transactions[2] = %+v, want credit of 25 for spin-1 with id 3", tx)
+	}
+}